@@ -16,6 +16,21 @@ type Config struct {
 	AdminToken    string `toml:"admin_token"`
 	Secret        string `toml:"secret"`
 	BaseURL       string `toml:"base_url"`
+
+	// APITokens are the tokens for the json api by the name of the client.
+	// Without a token, the api can not be used.
+	APITokens map[string]string `toml:"api_tokens"`
+
+	// The smtp settings are used to send mails. If SMTPHost is empty, no
+	// mails are sent.
+	SMTPHost     string `toml:"smtp_host"`
+	SMTPPort     int    `toml:"smtp_port"`
+	SMTPUser     string `toml:"smtp_user"`
+	SMTPPassword string `toml:"smtp_password"`
+	MailFrom     string `toml:"mail_from"`
+
+	Glaeubiger Glaeubiger `toml:"glaeubiger"`
+	Datev      Datev      `toml:"datev"`
 }
 
 // defaultConfig returns a config object with default values.
@@ -25,6 +40,9 @@ func defaultConfig() Config {
 		AdminToken:    CreatePassword(8),
 		Secret:        CreatePassword(32),
 		BaseURL:       "http://localhost",
+		SMTPPort:      587,
+		Glaeubiger:    defaultGlaeubiger(),
+		Datev:         defaultDatev(),
 	}
 }
 
@@ -47,6 +65,21 @@ func LoadConfig(file string) (Config, error) {
 	if err := toml.NewDecoder(f).Decode(&c); err != nil {
 		return Config{}, fmt.Errorf("reading config: %w", err)
 	}
+
+	c.Glaeubiger.normalize()
+	if err := c.Glaeubiger.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid glaeubiger: %w", err)
+	}
+
+	if err := c.Datev.validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid datev: %w", err)
+	}
+
+	for name, token := range c.APITokens {
+		if len(token) < 16 {
+			return Config{}, fmt.Errorf("api_tokens %q: the token has to be at least 16 characters long", name)
+		}
+	}
 	return c, nil
 }
 
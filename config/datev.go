@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Datev are the settings of the DATEV export for the tax advisor.
+type Datev struct {
+	Beraternummer   int `toml:"beraternummer"`
+	Mandantennummer int `toml:"mandantennummer"`
+
+	// Sachkontenlaenge is the number of digits of the accounts.
+	Sachkontenlaenge int `toml:"sachkontenlaenge"`
+
+	// Kontenrahmen is the chart of accounts, for example "03" for SKR 03.
+	Kontenrahmen string `toml:"kontenrahmen"`
+
+	// WirtschaftsjahrBeginn is the first month of the fiscal year. A
+	// Buchungsstapel can only contain bookings of one fiscal year.
+	WirtschaftsjahrBeginn int `toml:"wirtschaftsjahr_beginn"`
+
+	// Konto is debited with the contributions. Gegenkonto is the account of
+	// the income.
+	Konto      int `toml:"konto"`
+	Gegenkonto int `toml:"gegenkonto"`
+
+	// Kostenstelle is the cost centre of bieter without a verteilstelle.
+	Kostenstelle string `toml:"kostenstelle"`
+
+	// Kostenstellen are the cost centres by the id of the verteilstelle.
+	Kostenstellen map[string]string `toml:"kostenstellen"`
+
+	// BuchungstextVorlage is the text of a booking. The texts {bietnummer},
+	// {name}, {monat} and {saison} are replaced.
+	BuchungstextVorlage string `toml:"buchungstext"`
+}
+
+// defaultDatev returns the settings for SKR 03 with the income booked to
+// the bank account.
+func defaultDatev() Datev {
+	return Datev{
+		Sachkontenlaenge:      4,
+		Kontenrahmen:          "03",
+		WirtschaftsjahrBeginn: 1,
+		Konto:                 1200,
+		Gegenkonto:            8200,
+		BuchungstextVorlage:   "Beitrag {monat} {name}",
+	}
+}
+
+// KostenstelleFuer returns the cost centre of a verteilstelle.
+func (d Datev) KostenstelleFuer(verteilstelleID int) string {
+	if kost, ok := d.Kostenstellen[strconv.Itoa(verteilstelleID)]; ok {
+		return kost
+	}
+	return d.Kostenstelle
+}
+
+// Buchungstext returns the text of a booking.
+func (d Datev) Buchungstext(bietID int, name, monat, saisonKuerzel string) string {
+	return strings.NewReplacer(
+		"{bietnummer}", strconv.Itoa(bietID),
+		"{name}", name,
+		"{monat}", monat,
+		"{saison}", saisonKuerzel,
+	).Replace(d.BuchungstextVorlage)
+}
+
+// Bereit returns an error, if the numbers of the tax advisor are missing.
+// DATEV does not import a file without them.
+func (d Datev) Bereit() error {
+	if d.Beraternummer == 0 || d.Mandantennummer == 0 {
+		return fmt.Errorf("Für den DATEV-Export müssen beraternummer und mandantennummer in der config.toml gesetzt sein")
+	}
+	return nil
+}
+
+// kostenstelleZeichen are the characters, that DATEV allows in a cost
+// centre.
+var kostenstelleZeichen = regexp.MustCompile(`^[A-Za-z0-9]{0,36}$`)
+
+func (d Datev) validate() error {
+	// The numbers are only needed for the export, so they can be unset.
+	if d.Beraternummer != 0 && (d.Beraternummer < 1001 || d.Beraternummer > 9999999) {
+		return fmt.Errorf("beraternummer %d: has to be between 1001 and 9999999", d.Beraternummer)
+	}
+
+	if d.Mandantennummer != 0 && (d.Mandantennummer < 1 || d.Mandantennummer > 99999) {
+		return fmt.Errorf("mandantennummer %d: has to be between 1 and 99999", d.Mandantennummer)
+	}
+
+	if d.Sachkontenlaenge < 4 || d.Sachkontenlaenge > 8 {
+		return fmt.Errorf("sachkontenlaenge %d: has to be between 4 and 8", d.Sachkontenlaenge)
+	}
+
+	if d.WirtschaftsjahrBeginn < 1 || d.WirtschaftsjahrBeginn > 12 {
+		return fmt.Errorf("wirtschaftsjahr_beginn %d: has to be a month between 1 and 12", d.WirtschaftsjahrBeginn)
+	}
+
+	for name, konto := range map[string]int{"konto": d.Konto, "gegenkonto": d.Gegenkonto} {
+		if konto <= 0 || len(strconv.Itoa(konto)) > 9 {
+			return fmt.Errorf("%s %d: has to be a positive number with at most 9 digits", name, konto)
+		}
+	}
+
+	if !kostenstelleZeichen.MatchString(d.Kostenstelle) {
+		return fmt.Errorf("kostenstelle %q: has to be at most 36 characters of A-Z, a-z and 0-9", d.Kostenstelle)
+	}
+
+	for id, kost := range d.Kostenstellen {
+		if _, err := strconv.Atoi(id); err != nil {
+			return fmt.Errorf("kostenstellen: key %q is not the id of a verteilstelle", id)
+		}
+
+		if !kostenstelleZeichen.MatchString(kost) {
+			return fmt.Errorf("kostenstellen %q: has to be at most 36 characters of A-Z, a-z and 0-9", kost)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jbub/banking/iban"
+)
+
+// Glaeubiger is the creditor of the SEPA direct debits.
+type Glaeubiger struct {
+	// Name is the payee in the SEPA export.
+	Name string `toml:"name"`
+
+	// Verein is the full name of the association, that is used in the
+	// contract.
+	Verein string `toml:"verein"`
+
+	// Anschrift are the lines in the header of the contract.
+	Anschrift []string `toml:"anschrift"`
+
+	IBAN         string `toml:"iban"`
+	GlaeubigerID string `toml:"glaeubiger_id"`
+
+	// VerwendungszweckVorlage is the purpose of the payment. The text
+	// {saison} is replaced with the short form of the saison, for example
+	// "26.27".
+	VerwendungszweckVorlage string `toml:"verwendungszweck"`
+
+	// MandatsreferenzVorlage is the reference of the SEPA mandate. The text
+	// {bietnummer} is replaced with the id of the bieter.
+	MandatsreferenzVorlage string `toml:"mandatsreferenz"`
+}
+
+// defaultGlaeubiger returns the creditor data, that was hard coded, before it
+// was configurable.
+func defaultGlaeubiger() Glaeubiger {
+	return Glaeubiger{
+		Name:   "Solidarische Landwirtschaft",
+		Verein: "Solidarische Landwirtschaft Baarfood e.V.",
+		Anschrift: []string{
+			"Neckarstrasse 120",
+			"78056 Villingen-Schwenningen",
+			"www.baarfood.de",
+		},
+		IBAN:                    "DE24643901300278501001",
+		GlaeubigerID:            "DE62ZZZ00001997635",
+		VerwendungszweckVorlage: "Gemüseanteil {saison} Baarfood",
+		MandatsreferenzVorlage:  "25{bietnummer}",
+	}
+}
+
+// Mandatsreferenz returns the reference of a SEPA mandate of a bieter.
+//
+// The first mandate of a bieter only uses the template. Later mandates get
+// there number as suffix, for example "25123456789-2".
+func (g Glaeubiger) Mandatsreferenz(bietID int, mandatNummer int) string {
+	referenz := strings.ReplaceAll(g.MandatsreferenzVorlage, "{bietnummer}", strconv.Itoa(bietID))
+	if mandatNummer > 1 {
+		referenz += "-" + strconv.Itoa(mandatNummer)
+	}
+	return referenz
+}
+
+// Verwendungszweck returns the purpose of the payment for a saison.
+func (g Glaeubiger) Verwendungszweck(saisonKuerzel string) string {
+	return strings.ReplaceAll(g.VerwendungszweckVorlage, "{saison}", saisonKuerzel)
+}
+
+// sepaZeichen are the characters, that are allowed in a SEPA mandate
+// reference.
+var sepaZeichen = regexp.MustCompile(`^[A-Za-z0-9+?/\-:().,' ]+$`)
+
+// normalize removes the spaces from the IBAN and the creditor id.
+func (g *Glaeubiger) normalize() {
+	g.IBAN = strings.ToUpper(strings.ReplaceAll(g.IBAN, " ", ""))
+	g.GlaeubigerID = strings.ToUpper(strings.ReplaceAll(g.GlaeubigerID, " ", ""))
+}
+
+func (g Glaeubiger) validate() error {
+	if g.Name == "" {
+		return fmt.Errorf("name is empty")
+	}
+
+	if _, err := iban.Parse(g.IBAN); err != nil {
+		return fmt.Errorf("iban %q: %w", g.IBAN, err)
+	}
+
+	if err := validateGlaeubigerID(g.GlaeubigerID); err != nil {
+		return fmt.Errorf("glaeubiger_id %q: %w", g.GlaeubigerID, err)
+	}
+
+	if !strings.Contains(g.MandatsreferenzVorlage, "{bietnummer}") {
+		return fmt.Errorf("mandatsreferenz %q does not contain {bietnummer}", g.MandatsreferenzVorlage)
+	}
+
+	// The biggest bietnummer has 9 digits.
+	beispiel := g.Mandatsreferenz(999_999_999, 99)
+	if len(beispiel) > 35 || !sepaZeichen.MatchString(beispiel) {
+		return fmt.Errorf("mandatsreferenz %q: has to be at most 35 characters of A-Z, a-z, 0-9 and +?/-:().,' ", beispiel)
+	}
+
+	if verwendungszweck := g.Verwendungszweck("00.00"); len([]rune(verwendungszweck)) > 140 {
+		return fmt.Errorf("verwendungszweck %q: has to be at most 140 characters", verwendungszweck)
+	}
+
+	return nil
+}
+
+// validateGlaeubigerID checks the format and the check digits of a SEPA
+// creditor id.
+//
+// The check digits are calculated like the check digits of an iban, but
+// the creditor business code (position 5 to 7) is ignored.
+func validateGlaeubigerID(id string) error {
+	if len(id) < 9 || len(id) > 35 {
+		return fmt.Errorf("has to be between 9 and 35 characters")
+	}
+
+	for i, r := range id {
+		isLetter := r >= 'A' && r <= 'Z'
+		isDigit := r >= '0' && r <= '9'
+		switch {
+		case i < 2 && !isLetter:
+			return fmt.Errorf("has to start with a country code")
+		case i >= 2 && i < 4 && !isDigit:
+			return fmt.Errorf("position 3 and 4 have to be check digits")
+		case !isLetter && !isDigit:
+			return fmt.Errorf("invalid character %q", r)
+		}
+	}
+
+	var rest int
+	for _, r := range id[7:] + id[:2] + "00" {
+		if r >= 'A' && r <= 'Z' {
+			rest = (rest*100 + int(r-'A') + 10) % 97
+			continue
+		}
+		rest = (rest*10 + int(r-'0')) % 97
+	}
+
+	if expected := fmt.Sprintf("%02d", 98-rest); id[2:4] != expected {
+		return fmt.Errorf("wrong check digits, expected %s", expected)
+	}
+	return nil
+}
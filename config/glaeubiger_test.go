@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestGlaeubigerValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		change    func(g *Glaeubiger)
+		expectErr bool
+	}{
+		{"defaults", func(g *Glaeubiger) {}, false},
+		{"valid creditor id", func(g *Glaeubiger) { g.GlaeubigerID = "DE98ZZZ09999999999" }, false},
+		{"creditor id with spaces", func(g *Glaeubiger) { g.GlaeubigerID = "de98 zzz0 9999 9999 99" }, false},
+		{"other business code", func(g *Glaeubiger) { g.GlaeubigerID = "DE98ABC09999999999" }, false},
+		{"wrong check digits", func(g *Glaeubiger) { g.GlaeubigerID = "DE99ZZZ09999999999" }, true},
+		{"no country code", func(g *Glaeubiger) { g.GlaeubigerID = "9898ZZZ09999999999" }, true},
+		{"wrong iban", func(g *Glaeubiger) { g.IBAN = "DE24643901300278501002" }, true},
+		{"empty iban", func(g *Glaeubiger) { g.IBAN = "" }, true},
+		{"mandatsreferenz without bietnummer", func(g *Glaeubiger) { g.MandatsreferenzVorlage = "25" }, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g := defaultGlaeubiger()
+			tt.change(&g)
+			g.normalize()
+
+			err := g.validate()
+			if tt.expectErr != (err != nil) {
+				t.Errorf("got error %v, expected error: %t", err, tt.expectErr)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ require (
 	github.com/ostcar/sticky v0.0.0-20231023190144-833cde73829c
 	github.com/pelletier/go-toml/v2 v2.2.4
 	golang.org/x/exp v0.0.0-20251002181428-27f1f14c8bb9
+	golang.org/x/text v0.29.0
 )
 
 require (
@@ -29,6 +30,5 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/image v0.31.0 // indirect
-	golang.org/x/text v0.29.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
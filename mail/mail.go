@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+)
+
+// Sender sends mails over smtp.
+type Sender struct {
+	host     string
+	port     int
+	user     string
+	password string
+	from     string
+}
+
+// New initializes a Sender from the config.
+//
+// Returns nil, if no smtp host is configured. Calling Send on a nil Sender
+// does nothing.
+func New(cfg config.Config) *Sender {
+	if cfg.SMTPHost == "" {
+		return nil
+	}
+
+	return &Sender{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		user:     cfg.SMTPUser,
+		password: cfg.SMTPPassword,
+		from:     cfg.MailFrom,
+	}
+}
+
+// Send sends a plain text mail.
+func (s *Sender) Send(to string, subject string, body string) error {
+	if s == nil || to == "" {
+		return nil
+	}
+
+	var auth smtp.Auth
+	if s.user != "" {
+		auth = smtp.PlainAuth("", s.user, s.password, s.host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	addr := s.host + ":" + strconv.Itoa(s.port)
+	if err := smtp.SendMail(addr, auth, s.from, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("sending mail to %s: %w", to, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Laender are the countries, that can be selected for an address.
+var Laender = []struct {
+	Kennzeichen string
+	Name        string
+}{
+	{"DE", "Deutschland"},
+	{"CH", "Schweiz"},
+	{"AT", "Österreich"},
+	{"FR", "Frankreich"},
+}
+
+// Laendername returns the name of the country for the two letter code.
+//
+// Returns the code, if the country is unknown.
+func Laendername(kennzeichen string) string {
+	for _, land := range Laender {
+		if land.Kennzeichen == kennzeichen {
+			return land.Name
+		}
+	}
+	return kennzeichen
+}
+
+var (
+	plzDE        = regexp.MustCompile(`^\d{5}$`)
+	laenderCode  = regexp.MustCompile(`^[A-Z]{2}$`)
+	adresseOrt   = regexp.MustCompile(`^(?:(?:D|DE)\s*-\s*)?(\d{4,5})\s+(.+)$`)
+	adresseHaus  = regexp.MustCompile(`^(.+?)\s+(\d+\s*[a-zA-Z]?(?:\s*[-/]\s*\d+\s*[a-zA-Z]?)?)$`)
+	adresseLaden = strings.NewReplacer("\r\n", "\n", ",", "\n")
+)
+
+// Anschrift returns the address in two lines, for example
+// "Musterstraße 13\n12345 Musterstadt".
+//
+// The country is only added, if it is not Germany.
+func (b Bieter) Anschrift() string {
+	var zeilen []string
+	if strasse := strings.TrimSpace(b.Strasse + " " + b.Hausnummer); strasse != "" {
+		zeilen = append(zeilen, strasse)
+	}
+
+	if ort := strings.TrimSpace(b.PLZ + " " + b.Ort); ort != "" {
+		zeilen = append(zeilen, ort)
+	}
+
+	if b.Land != "" && b.Land != "DE" {
+		zeilen = append(zeilen, Laendername(b.Land))
+	}
+	return strings.Join(zeilen, "\n")
+}
+
+func (b Bieter) adresseValidieren(invalid map[string]string) {
+	if b.Strasse == "" {
+		invalid["strasse"] = "Straße darf nicht leer sein."
+	}
+
+	if b.Hausnummer == "" {
+		invalid["hausnummer"] = "Hausnummer darf nicht leer sein."
+	}
+
+	switch {
+	case b.PLZ == "":
+		invalid["plz"] = "Postleitzahl darf nicht leer sein."
+	case b.Land == "DE" && !plzDE.MatchString(b.PLZ):
+		invalid["plz"] = "Eine deutsche Postleitzahl hat fünf Ziffern."
+	}
+
+	if b.Ort == "" {
+		invalid["ort"] = "Ort darf nicht leer sein."
+	}
+
+	if !laenderCode.MatchString(b.Land) {
+		invalid["land"] = "Wähle ein Land aus."
+	}
+}
+
+// migrateAdresse splits the free text address of old events into the
+// separate fields.
+//
+// It is a best effort. Lines, that could not be parsed are put into the
+// field for the street, so no information is lost. Nothing is changed, if
+// the separate fields are already used.
+func migrateAdresse(s Stammdaten) Stammdaten {
+	if s.Adresse == "" {
+		return s
+	}
+
+	if s.Strasse != "" || s.PLZ != "" || s.Ort != "" {
+		s.Adresse = ""
+		return s
+	}
+
+	var zeilen []string
+	for zeile := range strings.SplitSeq(adresseLaden.Replace(s.Adresse), "\n") {
+		zeile = strings.TrimSpace(zeile)
+		if zeile == "" || strings.EqualFold(zeile, "Deutschland") {
+			continue
+		}
+		zeilen = append(zeilen, zeile)
+	}
+
+	var rest []string
+	for _, zeile := range zeilen {
+		if s.PLZ == "" {
+			if match := adresseOrt.FindStringSubmatch(zeile); match != nil {
+				s.PLZ = match[1]
+				s.Ort = match[2]
+				continue
+			}
+		}
+
+		if s.Hausnummer == "" {
+			if match := adresseHaus.FindStringSubmatch(zeile); match != nil {
+				s.Strasse = strings.Join(append(rest, match[1]), ", ")
+				s.Hausnummer = strings.ReplaceAll(match[2], " ", "")
+				rest = nil
+				continue
+			}
+		}
+
+		rest = append(rest, zeile)
+	}
+
+	if len(rest) > 0 {
+		s.Strasse = strings.Join(append([]string{s.Strasse}, rest...), ", ")
+		s.Strasse = strings.TrimPrefix(s.Strasse, ", ")
+	}
+
+	if len(s.PLZ) == 5 {
+		s.Land = "DE"
+	}
+
+	s.Adresse = ""
+	return s
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Archiv is the data of a previous saison. It is not changed anymore.
+type Archiv struct {
+	Nummer     int
+	Saison     Saison
+	Bieter     map[int]Bieter
+	Runden     []Runde
+	Zielbetrag Gebot
+	Richtwert  Richtwert
+	Einzuege   map[string]Einzug
+
+	Ruecklastschriften map[int]Ruecklastschrift
+	Zahlungen          map[int]Zahlung
+}
+
+// BieterListe returns the bieter of the archived saison ordered by there name.
+func (a Archiv) BieterListe() []Bieter {
+	bieter := make([]Bieter, 0, len(a.Bieter))
+	for _, b := range a.Bieter {
+		bieter = append(bieter, b)
+	}
+	slices.SortFunc(bieter, func(a, b Bieter) int {
+		return cmp.Or(
+			cmp.Compare(a.Name(), b.Name()),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return bieter
+}
+
+// Jahressumme returns the sum of the gebote of the archived saison for a year.
+func (a Archiv) Jahressumme() Gebot {
+	var sum Gebot
+	for _, bieter := range a.Bieter {
+		sum += bieter.Jahresbeitrag()
+	}
+	return sum
+}
+
+// ArchivListe returns all previous saisons ordered by there number.
+func (m Model) ArchivListe() []Archiv {
+	archiv := make([]Archiv, 0, len(m.Archiv))
+	for _, a := range m.Archiv {
+		archiv = append(archiv, a)
+	}
+	slices.SortFunc(archiv, func(a, b Archiv) int {
+		return cmp.Compare(a.Nummer, b.Nummer)
+	})
+	return archiv
+}
+
+// bieterIDVergeben returns true, if the id is used by a bieter in the current
+// or a previous saison.
+func (m Model) bieterIDVergeben(id int) bool {
+	if _, ok := m.Bieter[id]; ok {
+		return true
+	}
+
+	for _, a := range m.Archiv {
+		if _, ok := a.Bieter[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SaisonNeu archives the current saison and starts a new one.
+//
+// The master data of the given bieter is copied into the new saison. They
+// keep there bietnummer.
+func (m Model) SaisonNeu(saison Saison, bietIDs []int) Event {
+	return eventSaisonNeu{Saison: saison, BietIDs: bietIDs}
+}
+
+// uebernehmen returns the bieter with the master data for a new saison.
+//
+// The link to a teilpartner is only kept, if the teilpartner is also
+// taken into the new saison.
+func (b Bieter) uebernehmen(uebernommen map[int]bool, saison Saison) Bieter {
+	b.Gebot = 0
+	b.Anwesend = false
+	b.CanSelfEdit = false
+	b.TeilpartnerAnfrage = 0
+	b.Einladungscode = ""
+	b.Gebotsverlauf = nil
+
+	if !uebernommen[b.TeilpartnerID] {
+		b.TeilpartnerID = 0
+	}
+
+	// The contract of the new saison contains the mandate. If the old one
+	// expired, the signature gives a new mandate.
+	if !b.Mandat.Leer() && b.Mandat.Abgelaufen(saison.ErsteAbbuchung) {
+		b.Mandat = b.Mandat.neu(b.Mandat.IBAN, saison.MandatDatum)
+	}
+	return b
+}
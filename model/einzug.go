@@ -0,0 +1,327 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Sequenz is the sequence type of a SEPA direct debit.
+type Sequenz string
+
+// Sequence types of a SEPA direct debit.
+const (
+	SequenzErste    Sequenz = "FRST"
+	SequenzFolge    Sequenz = "RCUR"
+	SequenzEinmalig Sequenz = "OOFF"
+)
+
+// Lastschrift is one direct debit from a bieter.
+type Lastschrift struct {
+	BietID  int     `json:"bieter"`
+	Betrag  Gebot   `json:"betrag"`
+	Sequenz Sequenz `json:"sequenz"`
+
+	// Mandat is the number of the mandate of the bieter, that is used.
+	Mandat int `json:"mandat,omitempty"`
+
+	// Teilpartner is the bieter, that pays the other half of the anteil.
+	Teilpartner int `json:"teilpartner,omitempty"`
+
+	// Ruecklastschrift is the id of the return, that is collected again with
+	// this direct debit.
+	Ruecklastschrift int `json:"ruecklastschrift,omitempty"`
+
+	// Kontoinhaber, IBAN and MandatDatum are the account of the bieter at the
+	// time of the export, so the file can be created again with the same
+	// data. Einzuege, that were exported before, do not have them.
+	Kontoinhaber string    `json:"kontoinhaber,omitempty"`
+	IBAN         string    `json:"iban,omitempty"`
+	MandatDatum  time.Time `json:"mandat_datum,omitzero"`
+}
+
+// Einzug is the collection of the direct debits for one month.
+type Einzug struct {
+	// Monat is the first day of the month.
+	Monat       time.Time `json:"monat"`
+	Faelligkeit time.Time `json:"faelligkeit"`
+
+	// Exportiert is the time, when the einzug was exported. If a month is
+	// exported again, it is the time of the last export.
+	Exportiert time.Time `json:"exportiert"`
+
+	Lastschriften []Lastschrift `json:"lastschriften"`
+}
+
+// Summe returns the sum of all direct debits of the einzug.
+func (e Einzug) Summe() Gebot {
+	var sum Gebot
+	for _, l := range e.Lastschriften {
+		sum += l.Betrag
+	}
+	return sum
+}
+
+// EinzugMonat is the state of the einzug for one month of the saison.
+type EinzugMonat struct {
+	Monat time.Time
+
+	// Einzug is the exported einzug. If the month was not exported yet, it
+	// contains the direct debits, that would be exported.
+	Einzug     Einzug
+	Exportiert bool
+
+	// OhneIBAN are the direct debits of a month, that was not exported yet,
+	// from bieter without a valid IBAN. They are left out of the export.
+	OhneIBAN []Lastschrift
+}
+
+// monatSchluessel returns the key of a month in Model.Einzuege.
+func monatSchluessel(monat time.Time) string {
+	return monat.Format("2006-01")
+}
+
+// ersterTag returns the first day of the month.
+func ersterTag(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Einzug returns the exported einzug of a month.
+func (m Model) Einzug(monat time.Time) (Einzug, bool) {
+	einzug, ok := m.Einzuege[monatSchluessel(monat)]
+	return einzug, ok
+}
+
+// EinzugUebersicht returns the einzug for every month of the saison.
+func (m Model) EinzugUebersicht() []EinzugMonat {
+	var uebersicht []EinzugMonat
+	for _, monat := range m.Saison.Monate() {
+		var ohneIBAN []Lastschrift
+		einzug, ok := m.Einzug(monat)
+		if !ok {
+			einzug = Einzug{
+				Monat:       monat,
+				Faelligkeit: m.Faelligkeit(monat),
+			}
+			einzug.Lastschriften, ohneIBAN = m.EinzugLastschriften(monat)
+		}
+
+		uebersicht = append(uebersicht, EinzugMonat{
+			Monat:      monat,
+			Einzug:     einzug,
+			Exportiert: ok,
+			OhneIBAN:   ohneIBAN,
+		})
+	}
+	return uebersicht
+}
+
+// Termin is a due date of a direct debit from a bieter.
+type Termin struct {
+	Faelligkeit time.Time
+	Betrag      Gebot
+	Sequenz     Sequenz
+
+	// Nachholung is true, if the debit collects a return again.
+	Nachholung bool
+}
+
+// Zahlungsplaene returns the due dates of all direct debits of the saison
+// by bieter.
+//
+// For exported months, the exported direct debits are used. The other months
+// are planned with the current gebote, also for bieter, that have no valid
+// IBAN yet.
+func (m Model) Zahlungsplaene() map[int][]Termin {
+	plaene := make(map[int][]Termin)
+	for _, e := range m.EinzugUebersicht() {
+		for _, l := range slices.Concat(e.Einzug.Lastschriften, e.OhneIBAN) {
+			plaene[l.BietID] = append(plaene[l.BietID], Termin{
+				Faelligkeit: e.Einzug.Faelligkeit,
+				Betrag:      l.Betrag,
+				Sequenz:     l.Sequenz,
+				Nachholung:  l.Ruecklastschrift != 0,
+			})
+		}
+	}
+	return plaene
+}
+
+// Faelligkeit returns the due date for the einzug of a month.
+//
+// It is the first bank working day of the month. For the first month of the
+// saison, the date of the first debit of the saison is used.
+func (m Model) Faelligkeit(monat time.Time) time.Time {
+	monat = ersterTag(monat)
+	if monat.Equal(ersterTag(m.Saison.ErsteAbbuchung)) {
+		return m.Saison.ErsteAbbuchung
+	}
+	return NaechsterBankarbeitstag(monat)
+}
+
+// Lastschriften returns the direct debits for a month of the saison.
+//
+// Bieter that pay once a year are debited in the first month of the saison.
+// Bieter on the warteliste get no anteil and are not debited. Teilpartner
+// are debited separately from there own account. Returns, that are marked to
+// be collected again, are added.
+func (m Model) Lastschriften(monat time.Time) []Lastschrift {
+	monat = ersterTag(monat)
+	ersterMonat := monat.Equal(ersterTag(m.Saison.Start))
+
+	var lastschriften []Lastschrift
+	for _, bieter := range m.Bieter {
+		if bieter.Gebot.Empty() || bieter.Warteliste {
+			continue
+		}
+
+		betrag := bieter.Gebot
+		if bieter.Jaehrlich {
+			if !ersterMonat {
+				continue
+			}
+			betrag = bieter.Jahresbeitrag()
+		}
+
+		lastschriften = append(lastschriften, Lastschrift{
+			BietID:      bieter.ID,
+			Betrag:      betrag,
+			Sequenz:     m.sequenz(bieter, monat),
+			Mandat:      bieter.Mandat.Nummer,
+			Teilpartner: bieter.TeilpartnerID,
+		})
+	}
+
+	lastschriften = append(lastschriften, m.nachholungen(monat)...)
+
+	slices.SortFunc(lastschriften, func(a, b Lastschrift) int {
+		return cmp.Or(
+			cmp.Compare(a.BietID, b.BietID),
+			cmp.Compare(a.Ruecklastschrift, b.Ruecklastschrift),
+		)
+	})
+	return lastschriften
+}
+
+// sequenz returns the sequence type of a debit from the bieter in the month.
+//
+// It is derived from the mandate. For months after an exported einzug, that
+// did not record the use of the mandate yet, the debit is recurring.
+func (m Model) sequenz(bieter Bieter, monat time.Time) Sequenz {
+	sequenz := bieter.Mandat.Sequenz(m.Faelligkeit(monat))
+	if sequenz == SequenzErste && m.eingezogenVor(bieter, monat) {
+		return SequenzFolge
+	}
+	return sequenz
+}
+
+// eingezogenVor returns true, if the mandate of the bieter was used in an
+// exported einzug before the month.
+func (m Model) eingezogenVor(bieter Bieter, monat time.Time) bool {
+	for _, einzug := range m.Einzuege {
+		if !einzug.Monat.Before(monat) {
+			continue
+		}
+
+		for _, l := range einzug.Lastschriften {
+			if l.BietID == bieter.ID && l.Mandat == bieter.Mandat.Nummer && l.Sequenz != SequenzEinmalig {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EinzugLastschriften returns the direct debits of a month with the account
+// of the bieter, as they are exported.
+//
+// Debits from bieter without a valid IBAN are returned separately. They can
+// not be exported, since the bank would reject the whole file.
+func (m Model) EinzugLastschriften(monat time.Time) (lastschriften []Lastschrift, ohneIBAN []Lastschrift) {
+	for _, l := range m.Lastschriften(monat) {
+		bieter := m.Bieter[l.BietID]
+		l.Kontoinhaber = bieter.ShowKontoinhaber()
+		l.IBAN = bieter.IBANTrimed()
+		l.MandatDatum = m.Saison.MandatDatum
+		if !bieter.Mandat.Leer() {
+			l.MandatDatum = bieter.Mandat.Unterschrieben
+		}
+
+		if !ibanGueltig(l.IBAN) {
+			ohneIBAN = append(ohneIBAN, l)
+			continue
+		}
+		lastschriften = append(lastschriften, l)
+	}
+	return lastschriften, ohneIBAN
+}
+
+// EinzugExport records, that the einzug of a month was exported.
+//
+// Debits from bieter without a valid IBAN are not exported. If the month was
+// already exported, erneut has to be true.
+func (m Model) EinzugExport(monat time.Time, faelligkeit time.Time, erneut bool) Event {
+	monat = ersterTag(monat)
+	lastschriften, _ := m.EinzugLastschriften(monat)
+	return eventEinzugExport{
+		Monat:         monat,
+		Faelligkeit:   faelligkeit,
+		Lastschriften: lastschriften,
+		Erneut:        erneut,
+	}
+}
+
+// Bankarbeitstag returns true, if the day is a working day of the SEPA
+// payment system.
+//
+// The payment system is closed on weekends, new year, good friday, easter
+// monday, the first of may and the christmas days.
+func Bankarbeitstag(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+
+	switch {
+	case t.Month() == time.January && t.Day() == 1,
+		t.Month() == time.May && t.Day() == 1,
+		t.Month() == time.December && (t.Day() == 25 || t.Day() == 26):
+		return false
+	}
+
+	ostern := ostersonntag(t.Year())
+	tag := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if tag.Equal(ostern.AddDate(0, 0, -2)) || tag.Equal(ostern.AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+// NaechsterBankarbeitstag returns the day or the next day, that is a bank
+// working day.
+func NaechsterBankarbeitstag(t time.Time) time.Time {
+	tag := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	for !Bankarbeitstag(tag) {
+		tag = tag.AddDate(0, 0, 1)
+	}
+	return tag
+}
+
+// ostersonntag calculates the easter sunday of a year with the anonymous
+// gregorian algorithm.
+func ostersonntag(jahr int) time.Time {
+	a := jahr % 19
+	b := jahr / 100
+	c := jahr % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	monat := (h + l - 7*m + 114) / 31
+	tag := (h+l-7*m+114)%31 + 1
+	return time.Date(jahr, time.Month(monat), tag, 0, 0, 0, 0, time.UTC)
+}
@@ -1,56 +1,48 @@
 package model
 
-// Verteilstelle is an enum type
-type Verteilstelle int
+// Wochentag is an enum for the days of the week.
+type Wochentag int
 
-// Different enum values
+// These are the enum values for Wochentag.
 const (
-	VerteilstelleNone Verteilstelle = iota
-	VerteilstelleVillingen
-	VerteilstelleSchwenningen
-	VerteilstelleUeberauchen
+	WochentagNone Wochentag = iota
+	Montag
+	Dienstag
+	Mittwoch
+	Donnerstag
+	Freitag
+	Samstag
+	Sonntag
 )
 
-func (v Verteilstelle) String() string {
-	switch v {
-	case VerteilstelleVillingen:
-		return "Villingen"
-	case VerteilstelleSchwenningen:
-		return "Schwenningen"
-	case VerteilstelleUeberauchen:
-		return "Überauchen"
-	default:
-		return "-"
+// Wochentage returns all days of the week.
+func Wochentage() []Wochentag {
+	return []Wochentag{
+		Montag,
+		Dienstag,
+		Mittwoch,
+		Donnerstag,
+		Freitag,
+		Samstag,
+		Sonntag,
 	}
 }
 
-// VerteilstelleFromAttr converts a attribute string representation to
-// Verteilstelle.
-func VerteilstelleFromAttr(attr string) Verteilstelle {
-	switch attr {
-	case "villingen":
-		return VerteilstelleVillingen
-	case "schwenningen":
-		return VerteilstelleSchwenningen
-	case "ueberauchen":
-		return VerteilstelleUeberauchen
-	default:
-		return VerteilstelleNone
+func (w Wochentag) String() string {
+	all := [...]string{
+		"-",
+		"Montag",
+		"Dienstag",
+		"Mittwoch",
+		"Donnerstag",
+		"Freitag",
+		"Samstag",
+		"Sonntag",
 	}
-}
-
-// ToAttr converts the Verteilstelle to a simple string that can be used in select statements.
-func (v Verteilstelle) ToAttr() string {
-	switch v {
-	case VerteilstelleVillingen:
-		return "villingen"
-	case VerteilstelleSchwenningen:
-		return "schwenningen"
-	case VerteilstelleUeberauchen:
-		return "ueberauchen"
-	default:
-		return "-"
+	if int(w) < 0 || int(w) >= len(all) {
+		w = 0
 	}
+	return all[w]
 }
 
 // GanzOderHalb is an enum to tell, if the bieter wants to share his anteil.
@@ -68,6 +60,19 @@ func (v GanzOderHalb) String() string {
 	return v.ToAttr()
 }
 
+// Halb tells, if the bieter gets a half anteil.
+func (v GanzOderHalb) Halb() bool {
+	return v == HalberAnteilSuche || v == HalberAnteil
+}
+
+// Anteil returns the share equivalent. A half anteil counts as 0.5.
+func (v GanzOderHalb) Anteil() float64 {
+	if v.Halb() {
+		return 0.5
+	}
+	return 1
+}
+
 // GanzOderHalbFromAttr converts a attribute string representation to
 // GanzOderHalb.
 func GanzOderHalbFromAttr(attr string) GanzOderHalb {
@@ -1,7 +1,9 @@
 package model
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/ostcar/sticky"
@@ -17,18 +19,56 @@ func GetEvent(eventType string) Event {
 		return &eventBieterCreate{}
 	case eventBieterUpdate{}.Name():
 		return &eventBieterUpdate{}
+	case eventBieterStammdaten{}.Name():
+		return &eventBieterStammdaten{}
 	case eventBieterDelete{}.Name():
 		return &eventBieterDelete{}
 	case eventStateSet{}.Name():
 		return &eventStateSet{}
 	case eventGebot{}.Name():
 		return &eventGebot{}
-	case eventResetGebot{}.Name():
-		return &eventResetGebot{}
+	case eventNeueRunde{}.Name():
+		return &eventNeueRunde{}
 	case eventSetAnwesend{}.Name():
 		return &eventSetAnwesend{}
 	case eventSetCanSelfEdit{}.Name():
 		return &eventSetCanSelfEdit{}
+	case eventZielbetrag{}.Name():
+		return &eventZielbetrag{}
+	case eventRichtwert{}.Name():
+		return &eventRichtwert{}
+	case eventGebotsregeln{}.Name():
+		return &eventGebotsregeln{}
+	case eventSaison{}.Name():
+		return &eventSaison{}
+	case eventSaisonNeu{}.Name():
+		return &eventSaisonNeu{}
+	case eventVerteilstelleCreate{}.Name():
+		return &eventVerteilstelleCreate{}
+	case eventVerteilstelleUpdate{}.Name():
+		return &eventVerteilstelleUpdate{}
+	case eventVerteilstelleAktiv{}.Name():
+		return &eventVerteilstelleAktiv{}
+	case eventVerteilstelleZuweisen{}.Name():
+		return &eventVerteilstelleZuweisen{}
+	case eventTeilpartnerAnfrage{}.Name():
+		return &eventTeilpartnerAnfrage{}
+	case eventTeilpartnerBestaetigen{}.Name():
+		return &eventTeilpartnerBestaetigen{}
+	case eventTeilpartnerZuordnen{}.Name():
+		return &eventTeilpartnerZuordnen{}
+	case eventTeilpartnerLoesen{}.Name():
+		return &eventTeilpartnerLoesen{}
+	case eventEinladungscode{}.Name():
+		return &eventEinladungscode{}
+	case eventEinzugExport{}.Name():
+		return &eventEinzugExport{}
+	case eventRuecklastschrift{}.Name():
+		return &eventRuecklastschrift{}
+	case eventRuecklastschriftNachholen{}.Name():
+		return &eventRuecklastschriftNachholen{}
+	case eventZahlung{}.Name():
+		return &eventZahlung{}
 	default:
 		return nil
 	}
@@ -36,6 +76,12 @@ func GetEvent(eventType string) Event {
 
 type eventBieterCreate struct {
 	ID int `json:"id"`
+
+	// Stammdaten are only set, if the bieter was imported. Then the bieter is
+	// created with the data, because all events of an import are validated
+	// before the first bieter exists.
+	Stammdaten *Stammdaten `json:"stammdaten,omitempty"`
+	Warteliste bool        `json:"warteliste,omitempty"`
 }
 
 func (e eventBieterCreate) Name() string {
@@ -43,18 +89,44 @@ func (e eventBieterCreate) Name() string {
 }
 
 func (e eventBieterCreate) Validate(model Model) error {
-	if _, ok := model.Bieter[e.ID]; ok {
+	if model.bieterIDVergeben(e.ID) {
 		return fmt.Errorf("Bieter id is not unique")
 	}
 
+	if e.Stammdaten != nil && e.Stammdaten.VerteilstelleID != 0 {
+		verteilstelle, ok := model.Verteilstellen[e.Stammdaten.VerteilstelleID]
+		if !ok {
+			return fmt.Errorf("Verteilstelle existiert nicht")
+		}
+
+		if verteilstelle.Inaktiv {
+			return fmt.Errorf("Verteilstelle %s ist geschlossen", verteilstelle.Name)
+		}
+	}
+
 	return nil
 }
 
-func (e eventBieterCreate) Execute(model Model, time time.Time) Model {
-	model.Bieter[e.ID] = Bieter{ID: e.ID}
+func (e eventBieterCreate) Execute(model Model, now time.Time) Model {
+	bieter := Bieter{ID: e.ID}
+	if e.Stammdaten == nil {
+		model.Bieter[e.ID] = bieter
+		return model
+	}
+
+	bieter.Stammdaten = *e.Stammdaten
+	bieter = mandatAktualisieren(bieter, model.Saison, now)
+	bieter.Warteliste = e.Warteliste
+	if bieter.Warteliste {
+		bieter.WartelisteSeit = ereigniszeit(now)
+	}
+
+	model.setBieter(bieter)
 	return model
 }
 
+// eventBieterUpdate replaces all fields of a bieter. It is not created
+// anymore, but old databases contain it.
 type eventBieterUpdate struct {
 	Bieter
 }
@@ -64,17 +136,107 @@ func (e eventBieterUpdate) Name() string {
 }
 
 func (e eventBieterUpdate) Validate(model Model) error {
-	if _, ok := model.Bieter[e.ID]; !ok {
+	old, ok := model.Bieter[e.ID]
+	if !ok {
 		return fmt.Errorf("bieter does not exist")
 	}
 
-	// TODO
+	if e.VerteilstelleID != 0 {
+		verteilstelle, ok := model.Verteilstellen[e.VerteilstelleID]
+		if !ok {
+			return fmt.Errorf("Verteilstelle existiert nicht")
+		}
+
+		if verteilstelle.Inaktiv && old.VerteilstelleID != e.VerteilstelleID {
+			return fmt.Errorf("Verteilstelle %s ist geschlossen", verteilstelle.Name)
+		}
+
+		if !e.Warteliste && model.brauchtPlatz(e.Bieter) && !model.HatPlatz(e.Bieter) {
+			return fmt.Errorf("Verteilstelle %s ist voll", verteilstelle.Name)
+		}
+	}
 
 	return nil
 }
 
-func (e eventBieterUpdate) Execute(model Model, time time.Time) Model {
-	model.Bieter[e.ID] = e.Bieter
+func (e eventBieterUpdate) Execute(model Model, now time.Time) Model {
+	model = migrateLegacyVerteilstelle(model, e.VerteilstelleID)
+
+	old := model.Bieter[e.ID]
+	bieter := e.Bieter
+	bieter.Stammdaten = migrateAdresse(bieter.Stammdaten)
+	bieter.Version = old.Version
+	bieter.TeilpartnerID = old.TeilpartnerID
+	bieter.TeilpartnerAnfrage = old.TeilpartnerAnfrage
+	bieter.Einladungscode = old.Einladungscode
+	bieter.Gebotsverlauf = old.Gebotsverlauf
+	bieter.WartelisteSeit = old.WartelisteSeit
+	bieter.Mandat = old.Mandat
+	bieter = mandatAktualisieren(bieter, model.Saison, now)
+	switch {
+	case !bieter.Warteliste:
+		bieter.WartelisteSeit = time.Time{}
+	case bieter.WartelisteSeit.IsZero():
+		bieter.WartelisteSeit = ereigniszeit(now)
+	}
+
+	model.setBieter(bieter)
+
+	if bieter.GanzOderHalb != HalberAnteil {
+		model = teilpartnerTrennen(model, e.ID)
+	}
+	return model
+}
+
+type eventBieterStammdaten struct {
+	ID         int  `json:"id"`
+	Version    int  `json:"version"`
+	Warteliste bool `json:"warteliste"`
+	Stammdaten
+}
+
+func (e eventBieterStammdaten) Name() string {
+	return "bieter-stammdaten"
+}
+
+func (e eventBieterStammdaten) Validate(model Model) error {
+	old, ok := model.Bieter[e.ID]
+	if !ok {
+		return fmt.Errorf("bieter does not exist")
+	}
+
+	if old.Version != e.Version {
+		return fmt.Errorf("Die Daten von %s wurden in der Zwischenzeit geändert. Bitte lade die Seite neu und gib die Änderungen erneut ein.", cmp.Or(old.Name(), "diesem Bieter"))
+	}
+
+	if old.Warteliste && !e.Warteliste && old.VerteilstelleID == e.VerteilstelleID {
+		return fmt.Errorf("%s steht auf der Warteliste und kann nur von der Verwaltung nachrücken", cmp.Or(old.Name(), "Der Bieter"))
+	}
+
+	bieter := old
+	bieter.Stammdaten = e.Stammdaten
+	bieter.Warteliste = e.Warteliste
+	return eventBieterUpdate{bieter}.Validate(model)
+}
+
+func (e eventBieterStammdaten) Execute(model Model, now time.Time) Model {
+	bieter := model.Bieter[e.ID]
+	bieter.Stammdaten = migrateAdresse(e.Stammdaten)
+	bieter = mandatAktualisieren(bieter, model.Saison, now)
+
+	bieter.Warteliste = e.Warteliste
+	switch {
+	case !bieter.Warteliste:
+		bieter.WartelisteSeit = time.Time{}
+	case bieter.WartelisteSeit.IsZero():
+		bieter.WartelisteSeit = ereigniszeit(now)
+	}
+
+	model.setBieter(bieter)
+
+	if bieter.GanzOderHalb != HalberAnteil {
+		model = teilpartnerTrennen(model, e.ID)
+	}
 	return model
 }
 
@@ -95,7 +257,13 @@ func (e eventBieterDelete) Validate(model Model) error {
 }
 
 func (e eventBieterDelete) Execute(model Model, time time.Time) Model {
+	model = teilpartnerTrennen(model, e.ID)
 	delete(model.Bieter, e.ID)
+
+	for _, bieter := range model.TeilpartnerAnfragen(e.ID) {
+		bieter.TeilpartnerAnfrage = 0
+		model.setBieter(bieter)
+	}
 	return model
 }
 
@@ -115,14 +283,28 @@ func (e eventStateSet) Validate(model Model) error {
 	return nil
 }
 
-func (e eventStateSet) Execute(model Model, time time.Time) Model {
+func (e eventStateSet) Execute(model Model, now time.Time) Model {
+	runde := model.Runden[len(model.Runden)]
+	if e.State == StateOffer && model.State != StateOffer {
+		if runde.Start.IsZero() {
+			runde.Start = now
+		}
+		runde.Ende = time.Time{}
+	}
+
+	if e.State != StateOffer && model.State == StateOffer {
+		runde.Ende = now
+	}
+	model.Runden[runde.Nummer] = runde
+
 	model.State = e.State
 	return model
 }
 
 type eventGebot struct {
-	BietID int   `json:"bieter"`
-	Gebot  Gebot `json:"gebot"`
+	BietID     int   `json:"bieter"`
+	Gebot      Gebot `json:"gebot"`
+	Bestaetigt bool  `json:"bestaetigt,omitempty"`
 }
 
 func (e eventGebot) Name() string {
@@ -138,30 +320,68 @@ func (e eventGebot) Validate(model Model) error {
 		return fmt.Errorf("gebot muss größer 0 sein")
 	}
 
+	bieter := model.Bieter[e.BietID]
+	if err := model.Gebotsregeln.Fuer(bieter.GanzOderHalb).pruefen(e.Gebot); err != nil {
+		return err
+	}
+
+	if warnung := model.GebotWarnung(e.BietID, e.Gebot); warnung != "" && !e.Bestaetigt {
+		return fmt.Errorf("%s", warnung)
+	}
+
 	return nil
 }
 
-func (e eventGebot) Execute(model Model, time time.Time) Model {
+func (e eventGebot) Execute(model Model, now time.Time) Model {
 	bieter := model.Bieter[e.BietID]
 	bieter.Gebot = e.Gebot
-	model.Bieter[e.BietID] = bieter
+	bieter.Gebotsverlauf = append(bieter.Gebotsverlauf, GebotAenderung{
+		Zeit:  now,
+		Runde: len(model.Runden),
+		Gebot: e.Gebot,
+
+		Bestaetigt: e.Bestaetigt && model.GebotWarnung(e.BietID, e.Gebot) != "",
+	})
+	model.setBieter(bieter)
 	return model
 }
 
-type eventResetGebot struct{}
+// eventNeueRunde closes the current bidding round and starts a new one.
+//
+// The name of the event is from the time, when it only deleted all gebote.
+type eventNeueRunde struct{}
 
-func (e eventResetGebot) Name() string {
+func (e eventNeueRunde) Name() string {
 	return "gebot-reset"
 }
 
-func (e eventResetGebot) Validate(model Model) error {
+func (e eventNeueRunde) Validate(model Model) error {
+	if model.AktuelleRunde().Anzahl() == 0 {
+		return fmt.Errorf("In der aktuellen Runde wurde noch kein Gebot abgegeben")
+	}
 	return nil
 }
 
-func (e eventResetGebot) Execute(model Model, time time.Time) Model {
-	for k, bieter := range model.Bieter {
+func (e eventNeueRunde) Execute(model Model, time time.Time) Model {
+	runde := model.AktuelleRunde()
+	if runde.Anzahl() == 0 {
+		return model
+	}
+
+	if runde.Ende.IsZero() {
+		runde.Ende = time
+	}
+	model.Runden[runde.Nummer] = runde
+
+	neueRunde := Runde{Nummer: runde.Nummer + 1}
+	if model.State == StateOffer {
+		neueRunde.Start = time
+	}
+	model.Runden[neueRunde.Nummer] = neueRunde
+
+	for _, bieter := range model.Bieter {
 		bieter.Gebot = 0
-		model.Bieter[k] = bieter
+		model.setBieter(bieter)
 	}
 	return model
 }
@@ -186,7 +406,7 @@ func (e eventSetAnwesend) Validate(model Model) error {
 func (e eventSetAnwesend) Execute(model Model, time time.Time) Model {
 	bieter := model.Bieter[e.BietID]
 	bieter.Anwesend = e.Anwesend
-	model.Bieter[e.BietID] = bieter
+	model.setBieter(bieter)
 	return model
 }
 
@@ -210,6 +430,649 @@ func (e eventSetCanSelfEdit) Validate(model Model) error {
 func (e eventSetCanSelfEdit) Execute(model Model, time time.Time) Model {
 	bieter := model.Bieter[e.BietID]
 	bieter.CanSelfEdit = e.CanSelfEdit
-	model.Bieter[e.BietID] = bieter
+	model.setBieter(bieter)
+	return model
+}
+
+type eventZielbetrag struct {
+	Betrag Gebot `json:"betrag"`
+}
+
+func (e eventZielbetrag) Name() string {
+	return "zielbetrag"
+}
+
+func (e eventZielbetrag) Validate(model Model) error {
+	if e.Betrag < 0 {
+		return fmt.Errorf("Zielbetrag darf nicht negativ sein")
+	}
+
+	return nil
+}
+
+func (e eventZielbetrag) Execute(model Model, time time.Time) Model {
+	model.Zielbetrag = e.Betrag
+	return model
+}
+
+type eventRichtwert struct {
+	Richtwert
+}
+
+func (e eventRichtwert) Name() string {
+	return "richtwert"
+}
+
+func (e eventRichtwert) Validate(model Model) error {
+	if e.Ganz < 0 || e.Halb < 0 {
+		return fmt.Errorf("Richtwert darf nicht negativ sein")
+	}
+
+	return nil
+}
+
+func (e eventRichtwert) Execute(model Model, time time.Time) Model {
+	model.Richtwert = e.Richtwert
+	return model
+}
+
+type eventGebotsregeln struct {
+	Gebotsregeln
+}
+
+func (e eventGebotsregeln) Name() string {
+	return "gebotsregeln"
+}
+
+func (e eventGebotsregeln) Validate(model Model) error {
+	if err := e.Ganz.validate(); err != nil {
+		return err
+	}
+
+	return e.Halb.validate()
+}
+
+func (e eventGebotsregeln) Execute(model Model, time time.Time) Model {
+	model.Gebotsregeln = e.Gebotsregeln
+	return model
+}
+
+type eventSaison struct {
+	Saison
+}
+
+func (e eventSaison) Name() string {
+	return "saison"
+}
+
+func (e eventSaison) Validate(model Model) error {
+	if e.Saison.Name == "" {
+		return fmt.Errorf("Der Name des Gemüsejahres muss angegeben sein")
+	}
+
+	if e.Start.IsZero() || e.Ende.IsZero() || e.Ende.Before(e.Start) {
+		return fmt.Errorf("Der erste Monat muss vor dem letzten Monat liegen")
+	}
+
+	if e.ErsteAbbuchung.IsZero() || e.MandatDatum.IsZero() {
+		return fmt.Errorf("Das Datum der ersten Abbuchung und das Datum der Mandate müssen angegeben sein")
+	}
+
+	return nil
+}
+
+func (e eventSaison) Execute(model Model, time time.Time) Model {
+	model.Saison = e.Saison
+	return model
+}
+
+type eventSaisonNeu struct {
+	Saison  Saison `json:"saison"`
+	BietIDs []int  `json:"bieter"`
+}
+
+func (e eventSaisonNeu) Name() string {
+	return "saison-neu"
+}
+
+func (e eventSaisonNeu) Validate(model Model) error {
+	if model.State != StateFinish {
+		return fmt.Errorf("Ein neues Gemüsejahr kann erst nach dem Abschluss der Bietrunde begonnen werden")
+	}
+
+	if err := (eventSaison{e.Saison}).Validate(model); err != nil {
+		return err
+	}
+
+	if e.Saison.Name == model.Saison.Name {
+		return fmt.Errorf("Das Gemüsejahr %s gibt es bereits", e.Saison.Name)
+	}
+
+	for _, a := range model.Archiv {
+		if e.Saison.Name == a.Saison.Name {
+			return fmt.Errorf("Das Gemüsejahr %s gibt es bereits", e.Saison.Name)
+		}
+	}
+
+	for _, id := range e.BietIDs {
+		if _, ok := model.Bieter[id]; !ok {
+			return fmt.Errorf("Bieter %d existiert nicht", id)
+		}
+	}
+
+	return nil
+}
+
+func (e eventSaisonNeu) Execute(model Model, time time.Time) Model {
+	nummer := len(model.Archiv) + 1
+	model.Archiv[nummer] = Archiv{
+		Nummer:     nummer,
+		Saison:     model.Saison,
+		Bieter:     model.Bieter,
+		Runden:     model.AlleRunden(),
+		Zielbetrag: model.Zielbetrag,
+		Richtwert:  model.Richtwert,
+		Einzuege:   model.Einzuege,
+
+		Ruecklastschriften: model.Ruecklastschriften,
+		Zahlungen:          model.Zahlungen,
+	}
+
+	uebernommen := make(map[int]bool, len(e.BietIDs))
+	for _, id := range e.BietIDs {
+		uebernommen[id] = true
+	}
+
+	// The archived bieter map must not be changed, so the new saison gets a
+	// new map.
+	bieter := make(map[int]Bieter, len(e.BietIDs))
+	for _, id := range e.BietIDs {
+		bieter[id] = model.Bieter[id].uebernehmen(uebernommen, e.Saison)
+	}
+
+	model.Bieter = bieter
+	model.Runden = map[int]Runde{1: {Nummer: 1}}
+	model.Einzuege = make(map[string]Einzug)
+	model.Ruecklastschriften = make(map[int]Ruecklastschrift)
+	model.Zahlungen = make(map[int]Zahlung)
+	model.State = StateRegistration
+	model.Saison = e.Saison
+	return model
+}
+
+type eventVerteilstelleCreate struct {
+	Verteilstelle
+}
+
+func (e eventVerteilstelleCreate) Name() string {
+	return "verteilstelle-create"
+}
+
+func (e eventVerteilstelleCreate) Validate(model Model) error {
+	if e.ID <= 0 {
+		return fmt.Errorf("invalid verteilstelle id")
+	}
+
+	if _, ok := model.Verteilstellen[e.ID]; ok {
+		return fmt.Errorf("Verteilstelle id is not unique")
+	}
+
+	return validateVerteilstelle(model, e.Verteilstelle)
+}
+
+func (e eventVerteilstelleCreate) Execute(model Model, time time.Time) Model {
+	model.Verteilstellen[e.ID] = e.Verteilstelle
+	return model
+}
+
+type eventVerteilstelleUpdate struct {
+	Verteilstelle
+}
+
+func (e eventVerteilstelleUpdate) Name() string {
+	return "verteilstelle-update"
+}
+
+func (e eventVerteilstelleUpdate) Validate(model Model) error {
+	if _, ok := model.Verteilstellen[e.ID]; !ok {
+		return fmt.Errorf("Verteilstelle existiert nicht")
+	}
+
+	return validateVerteilstelle(model, e.Verteilstelle)
+}
+
+func (e eventVerteilstelleUpdate) Execute(model Model, time time.Time) Model {
+	verteilstelle := e.Verteilstelle
+	verteilstelle.Inaktiv = model.Verteilstellen[e.ID].Inaktiv
+	model.Verteilstellen[e.ID] = verteilstelle
+	return model
+}
+
+func validateVerteilstelle(model Model, verteilstelle Verteilstelle) error {
+	if verteilstelle.Name == "" {
+		return fmt.Errorf("Name der Verteilstelle darf nicht leer sein")
+	}
+
+	for _, other := range model.Verteilstellen {
+		if other.ID != verteilstelle.ID && other.Name == verteilstelle.Name {
+			return fmt.Errorf("Es gibt bereits eine Verteilstelle %s", verteilstelle.Name)
+		}
+	}
+
+	if verteilstelle.Abholtag < WochentagNone || verteilstelle.Abholtag > Sonntag {
+		return fmt.Errorf("invalid abholtag")
+	}
+
+	return nil
+}
+
+type eventVerteilstelleAktiv struct {
+	ID    int  `json:"id"`
+	Aktiv bool `json:"aktiv"`
+}
+
+func (e eventVerteilstelleAktiv) Name() string {
+	return "verteilstelle-aktiv"
+}
+
+func (e eventVerteilstelleAktiv) Validate(model Model) error {
+	if _, ok := model.Verteilstellen[e.ID]; !ok {
+		return fmt.Errorf("Verteilstelle existiert nicht")
+	}
+
+	return nil
+}
+
+func (e eventVerteilstelleAktiv) Execute(model Model, time time.Time) Model {
+	verteilstelle := model.Verteilstellen[e.ID]
+	verteilstelle.Inaktiv = !e.Aktiv
+	model.Verteilstellen[e.ID] = verteilstelle
+	return model
+}
+
+type eventVerteilstelleZuweisen struct {
+	BietID          int `json:"bieter"`
+	VerteilstelleID int `json:"verteilstelle"`
+}
+
+func (e eventVerteilstelleZuweisen) Name() string {
+	return "verteilstelle-zuweisen"
+}
+
+func (e eventVerteilstelleZuweisen) Validate(model Model) error {
+	bieter, ok := model.Bieter[e.BietID]
+	if !ok {
+		return fmt.Errorf("bieter does not exist")
+	}
+
+	verteilstelle, ok := model.Verteilstellen[e.VerteilstelleID]
+	if !ok {
+		return fmt.Errorf("Verteilstelle existiert nicht")
+	}
+
+	if verteilstelle.Inaktiv {
+		return fmt.Errorf("Verteilstelle %s ist geschlossen", verteilstelle.Name)
+	}
+
+	bieter.VerteilstelleID = e.VerteilstelleID
+	if !model.HatPlatz(bieter) {
+		return fmt.Errorf("Verteilstelle %s ist voll", verteilstelle.Name)
+	}
+
+	return nil
+}
+
+func (e eventVerteilstelleZuweisen) Execute(model Model, now time.Time) Model {
+	bieter := model.Bieter[e.BietID]
+	bieter.VerteilstelleID = e.VerteilstelleID
+	bieter.Warteliste = false
+	bieter.WartelisteSeit = time.Time{}
+	model.setBieter(bieter)
+	return model
+}
+
+type eventTeilpartnerAnfrage struct {
+	BietID    int `json:"bieter"`
+	PartnerID int `json:"partner"`
+}
+
+func (e eventTeilpartnerAnfrage) Name() string {
+	return "teilpartner-anfrage"
+}
+
+func (e eventTeilpartnerAnfrage) Validate(model Model) error {
+	bieter, ok := model.Bieter[e.BietID]
+	if !ok {
+		return fmt.Errorf("bieter does not exist")
+	}
+
+	partner, ok := model.Bieter[e.PartnerID]
+	if !ok {
+		return fmt.Errorf("Teilpartner existiert nicht")
+	}
+
+	if bieter.ID == partner.ID {
+		return fmt.Errorf("Du kannst nicht dein eigener Teilpartner sein")
+	}
+
+	if bieter.TeilpartnerID != 0 {
+		return fmt.Errorf("Du hast bereits einen Teilpartner")
+	}
+
+	if partner.TeilpartnerID != 0 {
+		return fmt.Errorf("%s hat bereits einen Teilpartner", partner.Name())
+	}
+
+	return nil
+}
+
+func (e eventTeilpartnerAnfrage) Execute(model Model, time time.Time) Model {
+	bieter := model.Bieter[e.BietID]
+	bieter.TeilpartnerAnfrage = e.PartnerID
+	model.setBieter(bieter)
+	return model
+}
+
+type eventTeilpartnerBestaetigen struct {
+	BietID    int `json:"bieter"`
+	PartnerID int `json:"partner"`
+}
+
+func (e eventTeilpartnerBestaetigen) Name() string {
+	return "teilpartner-bestaetigen"
+}
+
+func (e eventTeilpartnerBestaetigen) Validate(model Model) error {
+	bieter, ok := model.Bieter[e.BietID]
+	if !ok {
+		return fmt.Errorf("bieter does not exist")
+	}
+
+	partner, ok := model.Bieter[e.PartnerID]
+	if !ok {
+		return fmt.Errorf("Teilpartner existiert nicht")
+	}
+
+	if partner.TeilpartnerAnfrage != bieter.ID {
+		return fmt.Errorf("%s hat keine Anfrage an dich gestellt", partner.Name())
+	}
+
+	if bieter.TeilpartnerID != 0 || partner.TeilpartnerID != 0 {
+		return fmt.Errorf("Einer von euch hat bereits einen Teilpartner")
+	}
+
+	return nil
+}
+
+func (e eventTeilpartnerBestaetigen) Execute(model Model, time time.Time) Model {
+	return teilpartnerVerbinden(model, e.BietID, e.PartnerID)
+}
+
+type eventTeilpartnerZuordnen struct {
+	BietID    int `json:"bieter"`
+	PartnerID int `json:"partner"`
+}
+
+func (e eventTeilpartnerZuordnen) Name() string {
+	return "teilpartner-zuordnen"
+}
+
+func (e eventTeilpartnerZuordnen) Validate(model Model) error {
+	bieter, ok := model.Bieter[e.BietID]
+	if !ok {
+		return fmt.Errorf("bieter does not exist")
+	}
+
+	partner, ok := model.Bieter[e.PartnerID]
+	if !ok {
+		return fmt.Errorf("Teilpartner existiert nicht")
+	}
+
+	if bieter.ID == partner.ID {
+		return fmt.Errorf("Ein Bieter kann nicht sein eigener Teilpartner sein")
+	}
+
+	if bieter.TeilpartnerID != 0 || partner.TeilpartnerID != 0 {
+		return fmt.Errorf("Einer der Bieter hat bereits einen Teilpartner")
+	}
+
+	return nil
+}
+
+func (e eventTeilpartnerZuordnen) Execute(model Model, time time.Time) Model {
+	return teilpartnerVerbinden(model, e.BietID, e.PartnerID)
+}
+
+type eventTeilpartnerLoesen struct {
+	BietID    int `json:"bieter"`
+	PartnerID int `json:"partner"`
+}
+
+func (e eventTeilpartnerLoesen) Name() string {
+	return "teilpartner-loesen"
+}
+
+func (e eventTeilpartnerLoesen) Validate(model Model) error {
+	bieter, ok := model.Bieter[e.BietID]
+	if !ok {
+		return fmt.Errorf("bieter does not exist")
+	}
+
+	partner := model.Bieter[e.PartnerID]
+	if bieter.TeilpartnerID != e.PartnerID &&
+		bieter.TeilpartnerAnfrage != e.PartnerID &&
+		partner.TeilpartnerAnfrage != e.BietID {
+		return fmt.Errorf("Es gibt keine Verknüpfung zu diesem Teilpartner")
+	}
+
+	return nil
+}
+
+func (e eventTeilpartnerLoesen) Execute(model Model, time time.Time) Model {
+	if model.Bieter[e.BietID].TeilpartnerID == e.PartnerID {
+		model = teilpartnerTrennen(model, e.BietID)
+	}
+
+	for _, ids := range [][2]int{{e.BietID, e.PartnerID}, {e.PartnerID, e.BietID}} {
+		bieter, ok := model.Bieter[ids[0]]
+		if ok && bieter.TeilpartnerAnfrage == ids[1] {
+			bieter.TeilpartnerAnfrage = 0
+			model.setBieter(bieter)
+		}
+	}
+	return model
+}
+
+type eventEinladungscode struct {
+	BietID int    `json:"bieter"`
+	Code   string `json:"code"`
+}
+
+func (e eventEinladungscode) Name() string {
+	return "einladungscode"
+}
+
+func (e eventEinladungscode) Validate(model Model) error {
+	if _, ok := model.Bieter[e.BietID]; !ok {
+		return fmt.Errorf("bieter does not exist")
+	}
+
+	if _, ok := model.BieterFuerEinladung(e.Code); ok || e.Code == "" {
+		return fmt.Errorf("Einladungscode ist nicht eindeutig")
+	}
+
+	return nil
+}
+
+func (e eventEinladungscode) Execute(model Model, time time.Time) Model {
+	bieter := model.Bieter[e.BietID]
+	bieter.Einladungscode = e.Code
+	model.setBieter(bieter)
+	return model
+}
+
+type eventEinzugExport struct {
+	Monat         time.Time     `json:"monat"`
+	Faelligkeit   time.Time     `json:"faelligkeit"`
+	Lastschriften []Lastschrift `json:"lastschriften"`
+	Erneut        bool          `json:"erneut,omitempty"`
+}
+
+func (e eventEinzugExport) Name() string {
+	return "einzug-export"
+}
+
+func (e eventEinzugExport) Validate(model Model) error {
+	if model.State != StateFinish {
+		return fmt.Errorf("Lastschriften können erst nach dem Abschluss der Bietrunde eingezogen werden")
+	}
+
+	if !slices.ContainsFunc(model.Saison.Monate(), e.Monat.Equal) {
+		return fmt.Errorf("%s gehört nicht zum Gemüsejahr %s", Monatsname(e.Monat), model.Saison.Name)
+	}
+
+	if len(e.Lastschriften) == 0 {
+		return fmt.Errorf("Für %s gibt es keine Lastschriften", Monatsname(e.Monat))
+	}
+
+	if einzug, ok := model.Einzug(e.Monat); ok && !e.Erneut {
+		return fmt.Errorf("Der Einzug für %s wurde bereits am %s exportiert", Monatsname(e.Monat), einzug.Exportiert.Format("02.01.2006"))
+	}
+
+	for _, l := range e.Lastschriften {
+		if _, ok := model.Bieter[l.BietID]; !ok {
+			return fmt.Errorf("Bieter %d existiert nicht", l.BietID)
+		}
+
+		if !ibanGueltig(l.IBAN) {
+			return fmt.Errorf("Bieter %d hat keine gültige IBAN", l.BietID)
+		}
+	}
+
+	return nil
+}
+
+func (e eventEinzugExport) Execute(model Model, now time.Time) Model {
+	model.Einzuege[monatSchluessel(e.Monat)] = Einzug{
+		Monat:         e.Monat,
+		Faelligkeit:   e.Faelligkeit,
+		Exportiert:    now,
+		Lastschriften: e.Lastschriften,
+	}
+
+	for _, l := range e.Lastschriften {
+		if bieter, ok := model.Bieter[l.BietID]; ok && bieter.Mandat.Nummer == l.Mandat {
+			bieter.Mandat = bieter.Mandat.genutzt(e.Faelligkeit)
+			model.setBieter(bieter)
+		}
+
+		if r, ok := model.Ruecklastschriften[l.Ruecklastschrift]; ok {
+			r.NachgeholtIn = e.Monat
+			model.Ruecklastschriften[r.ID] = r
+		}
+	}
+	return model
+}
+
+type eventRuecklastschrift struct {
+	Ruecklastschrift
+}
+
+func (e eventRuecklastschrift) Name() string {
+	return "ruecklastschrift"
+}
+
+func (e eventRuecklastschrift) Validate(model Model) error {
+	if e.ID <= 0 {
+		return fmt.Errorf("invalid ruecklastschrift id")
+	}
+
+	if _, ok := model.Ruecklastschriften[e.ID]; ok {
+		return fmt.Errorf("Ruecklastschrift id is not unique")
+	}
+
+	if _, ok := model.Bieter[e.BietID]; !ok {
+		return fmt.Errorf("Bieter %d existiert nicht", e.BietID)
+	}
+
+	if e.Betrag <= 0 {
+		return fmt.Errorf("Der Betrag der Rücklastschrift muss positiv sein")
+	}
+
+	if model.RuecklastschriftBekannt(e.Ruecklastschrift) {
+		return fmt.Errorf("Die Rücklastschrift vom %s für Bieter %d wurde bereits erfasst", e.Datum.Format("02.01.2006"), e.BietID)
+	}
+
+	return nil
+}
+
+func (e eventRuecklastschrift) Execute(model Model, time time.Time) Model {
+	model.Ruecklastschriften[e.ID] = e.Ruecklastschrift
+	return model
+}
+
+type eventRuecklastschriftNachholen struct {
+	ID        int  `json:"id"`
+	Nachholen bool `json:"nachholen"`
+}
+
+func (e eventRuecklastschriftNachholen) Name() string {
+	return "ruecklastschrift-nachholen"
+}
+
+func (e eventRuecklastschriftNachholen) Validate(model Model) error {
+	r, ok := model.Ruecklastschriften[e.ID]
+	if !ok {
+		return fmt.Errorf("Rücklastschrift existiert nicht")
+	}
+
+	if !r.Offen() {
+		return fmt.Errorf("Die Rücklastschrift wurde bereits im %s nachgeholt", Monatsname(r.NachgeholtIn))
+	}
+
+	return nil
+}
+
+func (e eventRuecklastschriftNachholen) Execute(model Model, time time.Time) Model {
+	r := model.Ruecklastschriften[e.ID]
+	r.Nachholen = e.Nachholen
+	model.Ruecklastschriften[e.ID] = r
+	return model
+}
+
+type eventZahlung struct {
+	Zahlung
+}
+
+func (e eventZahlung) Name() string {
+	return "zahlung"
+}
+
+func (e eventZahlung) Validate(model Model) error {
+	if e.ID <= 0 {
+		return fmt.Errorf("invalid zahlung id")
+	}
+
+	if _, ok := model.Zahlungen[e.ID]; ok {
+		return fmt.Errorf("Zahlung id is not unique")
+	}
+
+	if _, ok := model.Bieter[e.BietID]; !ok {
+		return fmt.Errorf("Bieter %d existiert nicht", e.BietID)
+	}
+
+	if e.Betrag <= 0 {
+		return fmt.Errorf("Der Betrag der Zahlung muss positiv sein")
+	}
+
+	if model.ZahlungBekannt(e.Zahlung) {
+		return fmt.Errorf("Die Zahlung vom %s über %s wurde bereits erfasst", e.Datum.Format("02.01.2006"), e.Betrag)
+	}
+
+	return nil
+}
+
+func (e eventZahlung) Execute(model Model, time time.Time) Model {
+	model.Zahlungen[e.ID] = e.Zahlung
 	return model
 }
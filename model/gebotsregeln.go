@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+)
+
+// Gebotsgrenzen are the limits for a gebot for one type of anteil.
+//
+// A value of 0 means, that there is no limit.
+type Gebotsgrenzen struct {
+	// Minimum and Maximum are hard limits. A gebot outside of them is
+	// rejected.
+	Minimum Gebot `json:"minimum"`
+	Maximum Gebot `json:"maximum"`
+
+	// MaxProzent is the highest gebot in percent of the richtwert, that is
+	// accepted without a confirmation of the bieter.
+	MaxProzent int `json:"max_prozent"`
+}
+
+// Gebotsregeln are the limits for a gebot for a whole and a half anteil.
+type Gebotsregeln struct {
+	Ganz Gebotsgrenzen `json:"ganz"`
+	Halb Gebotsgrenzen `json:"halb"`
+}
+
+// Fuer returns the limits for the type of anteil.
+func (r Gebotsregeln) Fuer(anteil GanzOderHalb) Gebotsgrenzen {
+	if anteil.Halb() {
+		return r.Halb
+	}
+	return r.Ganz
+}
+
+// pruefen returns an error, if the gebot is outside of the hard limits.
+func (g Gebotsgrenzen) pruefen(gebot Gebot) error {
+	if gebot.Empty() {
+		return nil
+	}
+
+	if g.Minimum > 0 && gebot < g.Minimum {
+		return fmt.Errorf("Das Gebot muss mindestens %s sein", g.Minimum)
+	}
+
+	if g.Maximum > 0 && gebot > g.Maximum {
+		return fmt.Errorf("Das Gebot darf höchstens %s sein", g.Maximum)
+	}
+
+	return nil
+}
+
+// warnung returns a text, if the gebot is outside of the soft limit.
+func (g Gebotsgrenzen) warnung(gebot Gebot, richtwert Gebot) string {
+	if g.MaxProzent <= 0 || richtwert.Empty() {
+		return ""
+	}
+
+	if gebot.Prozent(richtwert) <= float64(g.MaxProzent) {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"Dein Gebot von %s ist mehr als %d %% des Richtwerts von %s. Bitte bestätige, dass das Gebot richtig ist.",
+		gebot,
+		g.MaxProzent,
+		richtwert,
+	)
+}
+
+// GebotWarnung returns a text, if the gebot of a bieter is outside of the soft
+// limit and has to be confirmed by the bieter.
+//
+// A gebot outside of the hard limits gets no warning, since it is rejected
+// anyway.
+func (m Model) GebotWarnung(bietID int, gebot Gebot) string {
+	bieter := m.Bieter[bietID]
+	grenzen := m.Gebotsregeln.Fuer(bieter.GanzOderHalb)
+	if grenzen.pruefen(gebot) != nil {
+		return ""
+	}
+	return grenzen.warnung(gebot, m.Richtwert.Fuer(bieter.GanzOderHalb))
+}
+
+// SetGebotsregeln sets the limits for the gebote.
+func (m Model) SetGebotsregeln(regeln Gebotsregeln) Event {
+	return eventGebotsregeln{regeln}
+}
+
+func (g Gebotsgrenzen) validate() error {
+	if g.Minimum < 0 || g.Maximum < 0 || g.MaxProzent < 0 {
+		return fmt.Errorf("Die Grenzen dürfen nicht negativ sein")
+	}
+
+	if g.Maximum > 0 && g.Minimum > g.Maximum {
+		return fmt.Errorf("Das Minimum darf nicht größer als das Maximum sein")
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"math/rand"
+	"net/mail"
+	"strings"
+)
+
+// BieterImport creates bieter with there stammdaten.
+//
+// All events can be written together. The bieter are put on the warteliste,
+// if there verteilstelle is full. The bieter before them in the list are
+// taken into account.
+func (m Model) BieterImport(stammdaten []Stammdaten) []Event {
+	belegung := make(map[int]float64)
+	vergeben := make(map[int]bool)
+
+	events := make([]Event, len(stammdaten))
+	for i, s := range stammdaten {
+		events[i] = m.bieterCreateStammdaten(s, vergeben, belegung)
+	}
+	return events
+}
+
+// BieterCreateStammdaten creates a new bieter with stammdaten.
+//
+// The bieter is put on the warteliste, if the verteilstelle is full.
+func (m Model) BieterCreateStammdaten(stammdaten Stammdaten) (int, Event) {
+	event := m.bieterCreateStammdaten(stammdaten, nil, nil)
+	return event.ID, event
+}
+
+// bieterCreateStammdaten creates the event for a new bieter. vergeben are
+// the ids and belegung the anteile per verteilstelle of the bieter, that
+// are created in the same write.
+func (m Model) bieterCreateStammdaten(s Stammdaten, vergeben map[int]bool, belegung map[int]float64) eventBieterCreate {
+	id := rand.Intn(899_999_999) + 100_000_000
+	for vergeben[id] || m.bieterIDVergeben(id) {
+		id = rand.Intn(899_999_999) + 100_000_000
+	}
+	if vergeben != nil {
+		vergeben[id] = true
+	}
+
+	s.IBAN = formatIBAN(s.IBAN)
+
+	var warteliste bool
+	if verteilstelle, ok := m.Verteilstellen[s.VerteilstelleID]; ok && verteilstelle.Kapazitaet > 0 {
+		anteil := s.GanzOderHalb.Anteil()
+		warteliste = m.Belegung(verteilstelle.ID)+belegung[verteilstelle.ID]+anteil > float64(verteilstelle.Kapazitaet)
+		if !warteliste && belegung != nil {
+			belegung[verteilstelle.ID] += anteil
+		}
+	}
+
+	return eventBieterCreate{ID: id, Stammdaten: &s, Warteliste: warteliste}
+}
+
+// Duplikat returns the bieter with the lowest bietnummer, that has the same
+// mail address or IBAN.
+func (m Model) Duplikat(s Stammdaten) (Bieter, bool) {
+	var duplikat Bieter
+	for _, b := range m.Bieter {
+		if GleicheStammdaten(b.Stammdaten, s) && (duplikat.ID == 0 || b.ID < duplikat.ID) {
+			duplikat = b
+		}
+	}
+	return duplikat, duplikat.ID != 0
+}
+
+// GleicheStammdaten returns true, if the mail address or the IBAN are the
+// same. Empty values are not compared.
+func GleicheStammdaten(a, b Stammdaten) bool {
+	if mailA, mailB := normalisierteMail(a.Mail), normalisierteMail(b.Mail); mailA != "" && mailA == mailB {
+		return true
+	}
+
+	ibanA := strings.ToUpper(strings.ReplaceAll(a.IBAN, " ", ""))
+	ibanB := strings.ToUpper(strings.ReplaceAll(b.IBAN, " ", ""))
+	return ibanA != "" && ibanA == ibanB
+}
+
+func normalisierteMail(adresse string) string {
+	if parsed, err := mail.ParseAddress(adresse); err == nil {
+		adresse = parsed.Address
+	}
+	return strings.ToLower(strings.TrimSpace(adresse))
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// mandatGueltigkeitMonate is the time after the last use, when a SEPA
+// mandate expires.
+const mandatGueltigkeitMonate = 36
+
+// Mandat is the SEPA direct debit mandate of a bieter.
+type Mandat struct {
+	// Nummer counts the mandates of a bieter. It is part of the mandate
+	// reference. A bieter gets a new mandate, when the IBAN changes or the
+	// mandate expired.
+	Nummer int `json:"nummer"`
+
+	// IBAN is the account, the mandate was given for.
+	IBAN string `json:"iban"`
+
+	Unterschrieben time.Time `json:"unterschrieben"`
+	ErsteNutzung   time.Time `json:"erste_nutzung,omitzero"`
+	LetzteNutzung  time.Time `json:"letzte_nutzung,omitzero"`
+}
+
+// Leer returns true, if the bieter never gave a mandate.
+func (m Mandat) Leer() bool {
+	return m.Nummer == 0
+}
+
+// Abgelaufen returns true, if the mandate was not used for 36 months before
+// the day. A mandate, that was never used, expires 36 months after the
+// signature.
+func (m Mandat) Abgelaufen(tag time.Time) bool {
+	letzte := m.LetzteNutzung
+	if letzte.IsZero() {
+		letzte = m.Unterschrieben
+	}
+	return !letzte.IsZero() && tag.After(letzte.AddDate(0, mandatGueltigkeitMonate, 0))
+}
+
+// Sequenz returns the sequence type for a direct debit on the due date.
+//
+// The first debit of a mandate is FRST. All later debits are RCUR.
+func (m Mandat) Sequenz(faelligkeit time.Time) Sequenz {
+	if m.ErsteNutzung.IsZero() || !m.ErsteNutzung.Before(faelligkeit) || m.Abgelaufen(faelligkeit) {
+		return SequenzErste
+	}
+	return SequenzFolge
+}
+
+// neu returns the next mandate of the bieter for the IBAN.
+func (m Mandat) neu(iban string, unterschrieben time.Time) Mandat {
+	return Mandat{
+		Nummer:         m.Nummer + 1,
+		IBAN:           iban,
+		Unterschrieben: unterschrieben,
+	}
+}
+
+// genutzt records, that the mandate was used for a direct debit.
+func (m Mandat) genutzt(faelligkeit time.Time) Mandat {
+	if m.ErsteNutzung.IsZero() || faelligkeit.Before(m.ErsteNutzung) {
+		m.ErsteNutzung = faelligkeit
+	}
+	if faelligkeit.After(m.LetzteNutzung) {
+		m.LetzteNutzung = faelligkeit
+	}
+	return m
+}
+
+// mandatAktualisieren creates a new mandate, if the IBAN of the bieter
+// changed.
+//
+// A new mandate is signed with the contract of the saison. If the IBAN
+// changes after the contract was signed, the bieter has to sign a new
+// mandate on that day.
+func mandatAktualisieren(bieter Bieter, saison Saison, now time.Time) Bieter {
+	iban := strings.ToUpper(bieter.IBANTrimed())
+	if iban == "" || iban == bieter.Mandat.IBAN {
+		return bieter
+	}
+
+	unterschrieben := tagVon(ereigniszeit(now).In(time.Local))
+	if unterschrieben.Before(saison.MandatDatum) {
+		unterschrieben = saison.MandatDatum
+	}
+
+	bieter.Mandat = bieter.Mandat.neu(iban, unterschrieben)
+	return bieter
+}
+
+// ereigniszeit returns the time of an event like it is saved in the database.
+//
+// sticky calls Execute with the local time, when the event is written, and
+// with the saved time in UTC, when the database is loaded. Values derived
+// from the time have to be the same in both cases.
+func ereigniszeit(now time.Time) time.Time {
+	return now.UTC().Truncate(time.Second)
+}
+
+// tagVon returns the day of a time without the clock.
+func tagVon(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jbub/banking/iban"
 )
@@ -68,24 +69,102 @@ func (g Gebot) ForYear() Gebot {
 	return g * 12
 }
 
+// Prozent returns the percentage of g from total.
+func (g Gebot) Prozent(total Gebot) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(g) * 100 / float64(total)
+}
+
+// Stammdaten are the fields of a bieter, that are entered by the bieter or
+// the admin.
+type Stammdaten struct {
+	Vorname  string `json:"vorname"`
+	Nachname string `json:"nachname"`
+	Mail     string `json:"mail"`
+
+	// Adresse is the address as free text. It was used, before the address
+	// was saved in separate fields. It is only set by old events and
+	// converted with migrateAdresse.
+	Adresse string `json:"adresse,omitempty"`
+
+	Strasse    string `json:"strasse"`
+	Hausnummer string `json:"hausnummer"`
+	PLZ        string `json:"plz"`
+	Ort        string `json:"ort"`
+	// Land is the two letter country code, for example "DE".
+	Land string `json:"land"`
+
+	Telefon  string `json:"telefon"`
+	Mitglied bool   `json:"mitglied"`
+	// VerteilstelleID is the id of the verteilstelle. Before the
+	// verteilstellen where saved in the model, the values 1 to 3 where used
+	// for hard coded values.
+	VerteilstelleID int          `json:"verteilstelle"`
+	GanzOderHalb    GanzOderHalb `json:"ganz_oder_halb"`
+	Teilpartner     string       `json:"teilpartner"`
+	// KontaktTeilen is true, if the bieter agrees, that his contact details
+	// are given to a proposed teilpartner.
+	KontaktTeilen bool   `json:"kontakt_teilen"`
+	IBAN          string `json:"iban"`
+	Kontoinhaber  string `json:"kontoinhaber"`
+	Jaehrlich     bool   `json:"jaehrlich"`
+}
+
 // Bieter is a person that makes an offer.
 type Bieter struct {
-	ID            int           `json:"id"`
-	Vorname       string        `json:"vorname"`
-	Nachname      string        `json:"nachname"`
-	Mail          string        `json:"mail"`
-	Adresse       string        `json:"adresse"`
-	Telefon       string        `json:"telefon"`
-	Mitglied      bool          `json:"mitglied"`
-	Verteilstelle Verteilstelle `json:"verteilstelle"`
-	GanzOderHalb  GanzOderHalb  `json:"ganz_oder_halb"`
-	Teilpartner   string        `json:"teilpartner"`
-	IBAN          string        `json:"iban"`
-	Kontoinhaber  string        `json:"kontoinhaber"`
-	Jaehrlich     bool          `json:"jaehrlich"`
-	Gebot         Gebot         `json:"gebot"`
-	Anwesend      bool          `json:"anwesend"`
-	CanSelfEdit   bool          `json:"can_edit"`
+	ID int `json:"id"`
+	Stammdaten
+	Gebot       Gebot `json:"gebot"`
+	Anwesend    bool  `json:"anwesend"`
+	CanSelfEdit bool  `json:"can_edit"`
+
+	// Version is increased with every change of the bieter. It is used to
+	// detect concurrent changes.
+	Version int `json:"version"`
+
+	// Warteliste is true, if the verteilstelle was full, when the bieter
+	// chose it.
+	Warteliste     bool      `json:"warteliste"`
+	WartelisteSeit time.Time `json:"warteliste_seit,omitzero"`
+
+	// TeilpartnerID is the bieter, that shares the anteil. Both bieter have
+	// confirmed the link.
+	TeilpartnerID int `json:"teilpartner_id,omitempty"`
+
+	// TeilpartnerAnfrage is the bieter, that was asked to share the anteil,
+	// but did not confirm yet.
+	TeilpartnerAnfrage int `json:"teilpartner_anfrage,omitempty"`
+
+	// Einladungscode can be given to another person, to be linked as
+	// teilpartner without telling the bietnummer.
+	Einladungscode string `json:"einladungscode,omitempty"`
+
+	// Gebotsverlauf are all changes of the gebot in the order they happened.
+	Gebotsverlauf []GebotAenderung `json:"gebotsverlauf,omitempty"`
+
+	// Mandat is the current SEPA mandate. It is created, when the bieter
+	// enters an IBAN.
+	Mandat Mandat `json:"mandat,omitzero"`
+}
+
+// GebotAenderung is a change of the gebot of a bieter.
+type GebotAenderung struct {
+	Zeit  time.Time `json:"zeit"`
+	Runde int       `json:"runde"`
+	Gebot Gebot     `json:"gebot"`
+
+	// Bestaetigt is true, if the bieter confirmed a gebot outside of the
+	// soft limit.
+	Bestaetigt bool `json:"bestaetigt,omitempty"`
+}
+
+// Jahresbeitrag returns the amount the bieter pays in a year.
+//
+// The gebot is always a monthly amount, also for bieter that pay once a year.
+func (b Bieter) Jahresbeitrag() Gebot {
+	return b.Gebot.ForYear()
 }
 
 // Name returns the full name.
@@ -126,15 +205,13 @@ func (b Bieter) InvalidFields() map[string]string {
 		}
 	}
 
-	if b.Adresse == "" {
-		invalid["adresse"] = "Adresse darf nicht leer sein."
-	}
+	b.adresseValidieren(invalid)
 
 	if !b.Mitglied {
 		invalid["mitglied"] = "Du und der Teilnehmer müssen Mitglieder im Verein sein."
 	}
 
-	if b.Verteilstelle.ToAttr() == "-" {
+	if b.VerteilstelleID == 0 {
 		invalid["verteilstelle"] = "Wähle eine Verteilstelle aus."
 	}
 
@@ -176,10 +253,84 @@ func (b Bieter) IBANTrimed() string {
 	return strings.ReplaceAll(b.IBAN, " ", "")
 }
 
+// ibanGueltig tells, if the value is a valid IBAN. Spaces are ignored.
+func ibanGueltig(value string) bool {
+	_, err := iban.Parse(strings.ReplaceAll(value, " ", ""))
+	return err == nil
+}
+
+// Runde is one bidding round.
+//
+// The gebote of the running round are saved in the bieter. They are copied
+// into the runde, when the runde is closed.
+type Runde struct {
+	Nummer int
+	Start  time.Time
+	Ende   time.Time
+	Gebote map[int]Gebot
+}
+
+// Summe returns the sum of all monthly gebote of the runde.
+func (r Runde) Summe() Gebot {
+	var sum Gebot
+	for _, g := range r.Gebote {
+		sum += g
+	}
+	return sum
+}
+
+// Anzahl returns the number of gebote in the runde.
+func (r Runde) Anzahl() int {
+	return len(r.Gebote)
+}
+
 // Model of the service.
 type Model struct {
 	Bieter map[int]Bieter
 	State  ServiceState
+
+	// Runden are all bidding rounds by there number. The round with the
+	// highest number is the current one.
+	Runden map[int]Runde
+
+	// Zielbetrag is the budget for the whole season that has to be covered
+	// by the gebote.
+	Zielbetrag Gebot
+
+	Richtwert Richtwert
+
+	Gebotsregeln Gebotsregeln
+
+	Verteilstellen map[int]Verteilstelle
+
+	Saison Saison
+
+	// Archiv are the previous saisons by there number.
+	Archiv map[int]Archiv
+
+	// Einzuege are the exported direct debits of the saison by the month in
+	// the form "2006-01".
+	Einzuege map[string]Einzug
+
+	// Ruecklastschriften are the returned direct debits by there id.
+	Ruecklastschriften map[int]Ruecklastschrift
+
+	// Zahlungen are the payments from the bank statements by there id.
+	Zahlungen map[int]Zahlung
+}
+
+// Richtwert is the monthly reference price for an anteil.
+type Richtwert struct {
+	Ganz Gebot `json:"ganz"`
+	Halb Gebot `json:"halb"`
+}
+
+// Fuer returns the richtwert for the type of anteil.
+func (r Richtwert) Fuer(anteil GanzOderHalb) Gebot {
+	if anteil.Halb() {
+		return r.Halb
+	}
+	return r.Ganz
 }
 
 // New returns an initialized model.
@@ -187,7 +338,85 @@ func New() Model {
 	return Model{
 		Bieter: make(map[int]Bieter),
 		State:  StateRegistration,
+		Runden: map[int]Runde{1: {Nummer: 1}},
+
+		Verteilstellen: make(map[int]Verteilstelle),
+		Saison:         defaultSaison(),
+		Archiv:         make(map[int]Archiv),
+		Einzuege:       make(map[string]Einzug),
+
+		Ruecklastschriften: make(map[int]Ruecklastschrift),
+		Zahlungen:          make(map[int]Zahlung),
+	}
+}
+
+// AktuelleRunde returns the current bidding round.
+//
+// The gebote of the returned runde are the gebote of the bieter.
+func (m Model) AktuelleRunde() Runde {
+	runde := m.Runden[len(m.Runden)]
+	runde.Gebote = make(map[int]Gebot)
+	for _, bieter := range m.Bieter {
+		if !bieter.Gebot.Empty() {
+			runde.Gebote[bieter.ID] = bieter.Gebot
+		}
+	}
+	return runde
+}
+
+// Jahressumme returns the sum of the gebote of all bieter for a year.
+func (m Model) Jahressumme() Gebot {
+	var sum Gebot
+	for _, bieter := range m.Bieter {
+		sum += bieter.Jahresbeitrag()
 	}
+	return sum
+}
+
+// DeckungProzent returns the percentage of the Zielbetrag, that is covered by
+// the gebote.
+func (m Model) DeckungProzent() float64 {
+	return m.Jahressumme().Prozent(m.Zielbetrag)
+}
+
+// Fehlbetrag returns the amount that is missing to reach the Zielbetrag.
+func (m Model) Fehlbetrag() Gebot {
+	return max(m.Zielbetrag-m.Jahressumme(), 0)
+}
+
+// RichtwertVergleich counts the gebote below, at and above the richtwert.
+func (m Model) RichtwertVergleich() (unter, gleich, ueber int) {
+	for _, bieter := range m.Bieter {
+		richtwert := m.Richtwert.Fuer(bieter.GanzOderHalb)
+		if bieter.Gebot.Empty() || richtwert.Empty() {
+			continue
+		}
+
+		switch {
+		case bieter.Gebot < richtwert:
+			unter++
+		case bieter.Gebot > richtwert:
+			ueber++
+		default:
+			gleich++
+		}
+	}
+	return unter, gleich, ueber
+}
+
+// BisherigeRunden returns all finished bidding rounds ordered by there number.
+func (m Model) BisherigeRunden() []Runde {
+	runden := make([]Runde, 0, len(m.Runden))
+	for nr := 1; nr < len(m.Runden); nr++ {
+		runden = append(runden, m.Runden[nr])
+	}
+	return runden
+}
+
+// AlleRunden returns all bidding rounds ordered by there number. The last one
+// is the current round.
+func (m Model) AlleRunden() []Runde {
+	return append(m.BisherigeRunden(), m.AktuelleRunde())
 }
 
 // BieterCreate creates a new bieter with empty data.
@@ -196,10 +425,37 @@ func (m Model) BieterCreate() (int, Event) {
 	return id, eventBieterCreate{ID: id}
 }
 
-// BieterUpdate updates all fields of a bieter.
-func (m Model) BieterUpdate(bieter Bieter) Event {
-	bieter.IBAN = formatIBAN(bieter.IBAN)
-	return eventBieterUpdate{bieter}
+// BieterUpdate updates the stammdaten of a bieter.
+//
+// version has to be the version of the bieter, that the changes are based on.
+// If the bieter was changed in the meantime, the event is rejected.
+//
+// If the verteilstelle of the bieter is full, the bieter is put on the
+// warteliste. A bieter on the warteliste stays there, as long as he keeps
+// the verteilstelle.
+func (m Model) BieterUpdate(bietID int, version int, stammdaten Stammdaten) Event {
+	stammdaten.IBAN = formatIBAN(stammdaten.IBAN)
+
+	bieter := m.Bieter[bietID]
+	warteliste := bieter.Warteliste
+	wartet := bieter.Warteliste && bieter.VerteilstelleID == stammdaten.VerteilstelleID
+	bieter.Stammdaten = stammdaten
+	if !wartet && m.brauchtPlatz(bieter) {
+		warteliste = !m.HatPlatz(bieter)
+	}
+
+	return eventBieterStammdaten{
+		ID:         bietID,
+		Version:    version,
+		Stammdaten: stammdaten,
+		Warteliste: warteliste,
+	}
+}
+
+// setBieter saves a changed bieter and increases its version.
+func (m Model) setBieter(bieter Bieter) {
+	bieter.Version++
+	m.Bieter[bieter.ID] = bieter
 }
 
 // BieterDelete deletes a bieter.
@@ -213,13 +469,26 @@ func (m Model) SetState(state ServiceState) Event {
 }
 
 // SetGebot sets the gebot for an user.
-func (m Model) SetGebot(bietID int, gebot Gebot) Event {
-	return eventGebot{BietID: bietID, Gebot: gebot}
+//
+// bestaetigt has to be true, if the gebot is outside of the soft limit of the
+// gebotsregeln.
+func (m Model) SetGebot(bietID int, gebot Gebot, bestaetigt bool) Event {
+	return eventGebot{BietID: bietID, Gebot: gebot, Bestaetigt: bestaetigt}
+}
+
+// NeueRunde closes the current bidding round and starts a new one.
+func (m Model) NeueRunde() Event {
+	return eventNeueRunde{}
 }
 
-// ResetGebot sets all gebote to 0.
-func (m Model) ResetGebot() Event {
-	return eventResetGebot{}
+// SetZielbetrag sets the budget for the season.
+func (m Model) SetZielbetrag(betrag Gebot) Event {
+	return eventZielbetrag{Betrag: betrag}
+}
+
+// SetRichtwert sets the reference prices.
+func (m Model) SetRichtwert(richtwert Richtwert) Event {
+	return eventRichtwert{richtwert}
 }
 
 // BieterSetAnwesend deletes a bieter.
@@ -255,7 +524,12 @@ func SortAdd(sort string, attr string) string {
 }
 
 // SortBieter sorts bieter based on the given sortList.
-func SortBieter(bieter []Bieter, sortList []string) []Bieter {
+func SortBieter(bieter []Bieter, verteilstellen []Verteilstelle, sortList []string) []Bieter {
+	verteilstellenNamen := make(map[int]string, len(verteilstellen))
+	for _, v := range verteilstellen {
+		verteilstellenNamen[v.ID] = v.Name
+	}
+
 	comparators := make([]func(a, b Bieter) int, 0, len(sortList))
 
 	for _, sortKey := range sortList {
@@ -283,7 +557,7 @@ func SortBieter(bieter []Bieter, sortList []string) []Bieter {
 			})
 		case "verteilstelle":
 			comparators = append(comparators, func(a, b Bieter) int {
-				return strings.Compare(a.Verteilstelle.String(), b.Verteilstelle.String())
+				return strings.Compare(verteilstellenNamen[a.VerteilstelleID], verteilstellenNamen[b.VerteilstelleID])
 			})
 
 		case "gebot":
@@ -1,6 +1,8 @@
 package model_test
 
 import (
+	"maps"
+	"slices"
 	"testing"
 	"time"
 
@@ -18,3 +20,914 @@ func TestBieterCreate(t *testing.T) {
 		t.Fatalf("sticky.New: %v", err)
 	}
 }
+
+func TestNeueRundeKeepsGebote(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":252350}}
+	{"time":"2023-10-20 18:16:00","type":"set-state","payload":{"State":3}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":252350,"gebot":8000}}
+	{"time":"2023-10-20 18:18:00","type":"set-state","payload":{"State":2}}
+	{"time":"2023-10-20 18:19:00","type":"gebot-reset","payload":{}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	runden := m.AlleRunden()
+	if len(runden) != 2 {
+		t.Fatalf("got %d runden, expected 2", len(runden))
+	}
+
+	if got := runden[0].Gebote[252350]; got != 8000 {
+		t.Errorf("gebot in runde 1 is %d, expected 8000", got)
+	}
+
+	if got := runden[0].Ende.Format(time.DateTime); got != "2023-10-20 18:18:00" {
+		t.Errorf("runde 1 ended at %s, expected 2023-10-20 18:18:00", got)
+	}
+
+	if got := m.Bieter[252350].Gebot; got != 0 {
+		t.Errorf("gebot in runde 2 is %d, expected 0", got)
+	}
+
+	verlauf := m.Bieter[252350].Gebotsverlauf
+	if len(verlauf) != 1 || verlauf[0].Runde != 1 || verlauf[0].Zeit.Format(time.DateTime) != "2023-10-20 18:17:00" {
+		t.Errorf("got gebotsverlauf %v, expected one gebot in runde 1 at 2023-10-20 18:17:00", verlauf)
+	}
+}
+
+func TestLegacyVerteilstelle(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":252350}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":252350,"vorname":"Max","verteilstelle":2}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	if got := m.Verteilstelle(m.Bieter[252350]).Name; got != "Schwenningen" {
+		t.Errorf("got verteilstelle %q, expected Schwenningen", got)
+	}
+
+	id, _ := m.VerteilstelleCreate(model.Verteilstelle{Name: "Rottweil"})
+	if id != 3 {
+		t.Errorf("new verteilstelle got id %d, expected 3", id)
+	}
+}
+
+func TestTeilpartner(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:59","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:16:00","type":"teilpartner-anfrage","payload":{"bieter":1,"partner":2}}
+	{"time":"2023-10-20 18:16:01","type":"teilpartner-bestaetigen","payload":{"bieter":2,"partner":1}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	partner, ok := m.Teilpartner(m.Bieter[1])
+	if !ok || partner.ID != 2 {
+		t.Errorf("got teilpartner %d, expected 2", partner.ID)
+	}
+
+	if got := m.Anteile(); got != 1 {
+		t.Errorf("got %.1f anteile, expected 1", got)
+	}
+
+	if got := m.Paare(); got != 1 {
+		t.Errorf("got %d paare, expected 1", got)
+	}
+}
+
+func TestTeilpartnerVorschlaege(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"verteilstelle-create","payload":{"id":1,"name":"Villingen"}}
+	{"time":"2023-10-20 18:15:58","type":"verteilstelle-create","payload":{"id":2,"name":"Schwenningen"}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":3}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-update","payload":{"id":1,"verteilstelle":1,"ganz_oder_halb":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-update","payload":{"id":2,"verteilstelle":2,"ganz_oder_halb":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-update","payload":{"id":3,"verteilstelle":1,"ganz_oder_halb":3}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	vorschlaege := m.TeilpartnerVorschlaege()
+	if len(vorschlaege) != 1 {
+		t.Fatalf("got %d vorschlaege, expected 1", len(vorschlaege))
+	}
+
+	if vorschlaege[0].Bieter.ID != 1 || vorschlaege[0].Partner.ID != 3 {
+		t.Errorf("got vorschlag %d and %d, expected 1 and 3", vorschlaege[0].Bieter.ID, vorschlaege[0].Partner.ID)
+	}
+
+	if ohne := m.OhneVorschlag(vorschlaege); len(ohne) != 1 || ohne[0].ID != 2 {
+		t.Errorf("got %v without vorschlag, expected bieter 2", ohne)
+	}
+}
+
+func TestSaison(t *testing.T) {
+	saison := model.New().Saison
+
+	if got := saison.Kuerzel(); got != "26.27" {
+		t.Errorf("got kuerzel %q, expected 26.27", got)
+	}
+
+	if got := saison.Zeitraum(); got != "April 2026 – März 2027" {
+		t.Errorf("got zeitraum %q, expected April 2026 – März 2027", got)
+	}
+
+	if got := len(saison.Monate()); got != 12 {
+		t.Errorf("got %d monate, expected 12", got)
+	}
+}
+
+func TestSaisonNeu(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":1,"vorname":"Max","iban":"DE02120300000000202051"}}
+	{"time":"2023-10-20 18:16:00","type":"set-state","payload":{"State":3}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":1,"gebot":8000}}
+	{"time":"2023-10-20 18:18:00","type":"set-state","payload":{"State":4}}
+	{"time":"2023-10-20 18:19:00","type":"saison-neu","payload":{"saison":{"name":"2027/2028"},"bieter":[1]}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	bieter, ok := m.Bieter[1]
+	if !ok || len(m.Bieter) != 1 {
+		t.Fatalf("got bieter %v, expected only bieter 1", m.Bieter)
+	}
+
+	if bieter.Vorname != "Max" || bieter.Gebot != 0 {
+		t.Errorf("got bieter %v, expected Max without gebot", bieter)
+	}
+
+	archiv := m.ArchivListe()
+	if len(archiv) != 1 || archiv[0].Saison.Name != "2026/2027" {
+		t.Fatalf("got archiv %v, expected saison 2026/2027", archiv)
+	}
+
+	if got := archiv[0].Bieter[1].Gebot; got != 8000 {
+		t.Errorf("archived gebot is %d, expected 8000", got)
+	}
+}
+
+func TestGebotsregeln(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:16:00","type":"richtwert","payload":{"ganz":8000}}
+	{"time":"2023-10-20 18:16:00","type":"gebotsregeln","payload":{"ganz":{"minimum":2000,"maximum":50000,"max_prozent":150}}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		gebot      model.Gebot
+		bestaetigt bool
+		expectErr  bool
+	}{
+		{"normal", 9000, false, false},
+		{"below minimum", 1000, false, true},
+		{"above maximum", 60000, true, true},
+		{"soft limit", 20000, false, true},
+		{"soft limit confirmed", 20000, true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Write(func(m model.Model) model.Event { return m.SetGebot(1, tt.gebot, tt.bestaetigt) })
+			if tt.expectErr != (err != nil) {
+				t.Errorf("got error %v, expected error: %t", err, tt.expectErr)
+			}
+		})
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	verlauf := m.Bieter[1].Gebotsverlauf
+	if len(verlauf) != 2 || !verlauf[1].Bestaetigt {
+		t.Errorf("got gebotsverlauf %v, expected a confirmed second gebot", verlauf)
+	}
+}
+
+func TestBieterStammdatenVersion(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	version := m.Bieter[1].Version
+	done()
+
+	if err := s.Write(func(m model.Model) model.Event { return m.SetGebot(1, 8000, false) }); err != nil {
+		t.Fatalf("set gebot: %v", err)
+	}
+
+	stammdaten := model.Stammdaten{Vorname: "Max"}
+	if err := s.Write(func(m model.Model) model.Event { return m.BieterUpdate(1, version, stammdaten) }); err == nil {
+		t.Errorf("update with an old version succeeded, expected an error")
+	}
+
+	m, done = s.ForReading()
+	version = m.Bieter[1].Version
+	done()
+
+	if err := s.Write(func(m model.Model) model.Event { return m.BieterUpdate(1, version, stammdaten) }); err != nil {
+		t.Fatalf("update with the current version: %v", err)
+	}
+
+	m, done = s.ForReading()
+	defer done()
+
+	if got := m.Bieter[1]; got.Vorname != "Max" || got.Gebot != 8000 {
+		t.Errorf("got bieter %s with gebot %s, expected Max with gebot 80,00 €", got.Vorname, got.Gebot)
+	}
+}
+
+func TestMigrateAdresse(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":3}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":1,"adresse":"Musterstraße 13a\n78048 Villingen"}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":2,"adresse":"c/o Müller, Am Markt 1 - 3, D-78050 VS-Villingen"}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":3,"adresse":"Irgendwo im Wald"}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	for _, tt := range []struct {
+		id     int
+		expect model.Stammdaten
+	}{
+		{1, model.Stammdaten{Strasse: "Musterstraße", Hausnummer: "13a", PLZ: "78048", Ort: "Villingen", Land: "DE"}},
+		{2, model.Stammdaten{Strasse: "c/o Müller, Am Markt", Hausnummer: "1-3", PLZ: "78050", Ort: "VS-Villingen", Land: "DE"}},
+		{3, model.Stammdaten{Strasse: "Irgendwo im Wald"}},
+	} {
+		if got := m.Bieter[tt.id].Stammdaten; got != tt.expect {
+			t.Errorf("bieter %d: got address %q %q %q %q %q, expected %q %q %q %q %q", tt.id, got.Strasse, got.Hausnummer, got.PLZ, got.Ort, got.Land, tt.expect.Strasse, tt.expect.Hausnummer, tt.expect.PLZ, tt.expect.Ort, tt.expect.Land)
+		}
+	}
+}
+
+func TestEinzug(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":3}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":1,"iban":"DE02120300000000202051"}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":2,"jaehrlich":true,"iban":"DE02100100100006820101"}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":3,"iban":"DE00123"}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":1,"gebot":8000}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":2,"gebot":9000}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":3,"gebot":7000}}
+	{"time":"2023-10-20 18:18:00","type":"set-state","payload":{"State":4}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	april := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	mai := time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	export := func(monat time.Time, erneut bool) error {
+		return s.Write(func(m model.Model) model.Event {
+			return m.EinzugExport(monat, m.Faelligkeit(monat), erneut)
+		})
+	}
+
+	if err := export(april, false); err != nil {
+		t.Fatalf("export april: %v", err)
+	}
+
+	if err := export(april, false); err == nil {
+		t.Errorf("exporting april twice succeeded, expected an error")
+	}
+
+	if err := export(april, true); err != nil {
+		t.Errorf("exporting april again: %v", err)
+	}
+
+	if err := export(mai, false); err != nil {
+		t.Fatalf("export mai: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	einzugApril, _ := m.Einzug(april)
+	expectApril := []model.Lastschrift{
+		{BietID: 1, Betrag: 8000, Sequenz: model.SequenzErste, Mandat: 1},
+		{BietID: 2, Betrag: 108000, Sequenz: model.SequenzErste, Mandat: 1},
+	}
+	if got := ohneKonto(einzugApril.Lastschriften); !slices.Equal(got, expectApril) {
+		t.Errorf("got lastschriften %v in april, expected %v", got, expectApril)
+	}
+
+	if got := einzugApril.Lastschriften[1].IBAN; got != "DE02100100100006820101" {
+		t.Errorf("got iban %q in the einzug, expected the iban of the bieter", got)
+	}
+
+	einzugMai, _ := m.Einzug(mai)
+	expectMai := []model.Lastschrift{{BietID: 1, Betrag: 8000, Sequenz: model.SequenzFolge, Mandat: 1}}
+	if got := ohneKonto(einzugMai.Lastschriften); !slices.Equal(got, expectMai) {
+		t.Errorf("got lastschriften %v in mai, expected %v", got, expectMai)
+	}
+
+	// Bieter 3 has no valid IBAN. He is not exported, his mandate is not
+	// used and he is shown in the open months.
+	if got := m.Bieter[3].Mandat.ErsteNutzung; !got.IsZero() {
+		t.Errorf("the mandate of bieter 3 was used on %s, expected it to be unused", got)
+	}
+
+	for _, e := range m.EinzugUebersicht() {
+		if e.Exportiert != (len(e.OhneIBAN) == 0) {
+			t.Errorf("got %d debits without iban in %s, exportiert %t", len(e.OhneIBAN), model.Monatsname(e.Monat), e.Exportiert)
+		}
+	}
+
+	// The first of may is a holiday and the 2nd and 3rd are a weekend.
+	if got := einzugMai.Faelligkeit.Format(time.DateOnly); got != "2026-05-04" {
+		t.Errorf("got faelligkeit %s in mai, expected 2026-05-04", got)
+	}
+
+	// Good friday 2026 is the 3rd of april.
+	if model.Bankarbeitstag(time.Date(2026, time.April, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("good friday is a bank working day")
+	}
+}
+
+func TestRuecklastschriftNachholen(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":1,"iban":"DE02120300000000202051"}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":1,"gebot":8000}}
+	{"time":"2023-10-20 18:18:00","type":"set-state","payload":{"State":4}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	april := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	mai := time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	export := func(monat time.Time, erneut bool) error {
+		return s.Write(func(m model.Model) model.Event {
+			return m.EinzugExport(monat, m.Faelligkeit(monat), erneut)
+		})
+	}
+
+	if err := export(april, false); err != nil {
+		t.Fatalf("export april: %v", err)
+	}
+
+	rueckgabe := model.Ruecklastschrift{
+		BietID:  1,
+		Betrag:  8000,
+		Datum:   time.Date(2026, time.April, 8, 0, 0, 0, 0, time.UTC),
+		Grund:   "AM04",
+		Monat:   april,
+		Sequenz: model.SequenzErste,
+	}
+
+	erfassen := func() error {
+		m, write, done := s.ForWriting()
+		defer done()
+		return write(m.RuecklastschriftenErfassen([]model.Ruecklastschrift{rueckgabe})...)
+	}
+
+	if err := erfassen(); err != nil {
+		t.Fatalf("erfassen: %v", err)
+	}
+
+	if err := erfassen(); err == nil {
+		t.Errorf("importing the same return twice succeeded, expected an error")
+	}
+
+	if err := s.Write(func(m model.Model) model.Event { return m.RuecklastschriftNachholen(1, true) }); err != nil {
+		t.Fatalf("nachholen: %v", err)
+	}
+
+	if err := export(mai, false); err != nil {
+		t.Fatalf("export mai: %v", err)
+	}
+
+	// Exporting again must keep the collection of the return.
+	if err := export(mai, true); err != nil {
+		t.Fatalf("export mai again: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	einzugMai, _ := m.Einzug(mai)
+	expectMai := []model.Lastschrift{
+		{BietID: 1, Betrag: 8000, Sequenz: model.SequenzFolge, Mandat: 1},
+		{BietID: 1, Betrag: 8000, Sequenz: model.SequenzFolge, Mandat: 1, Ruecklastschrift: 1},
+	}
+	if got := ohneKonto(einzugMai.Lastschriften); !slices.Equal(got, expectMai) {
+		t.Errorf("got lastschriften %v in mai, expected %v", got, expectMai)
+	}
+
+	if got := m.Ruecklastschriften[1].NachgeholtIn; !got.Equal(mai) {
+		t.Errorf("got nachgeholt in %s, expected %s", got, mai)
+	}
+
+	if len(m.OffeneRuecklastschriften()) != 0 {
+		t.Errorf("got open returns %v, expected none", m.OffeneRuecklastschriften())
+	}
+
+	juni := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	if got := len(m.Lastschriften(juni)); got != 1 {
+		t.Errorf("got %d lastschriften in june, expected 1", got)
+	}
+}
+
+func TestMandat(t *testing.T) {
+	now := func() time.Time { return time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC) }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":1,"iban":"DE02 1203 0000 0000 2020 51"}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":1,"gebot":8000}}
+	{"time":"2023-10-20 18:18:00","type":"set-state","payload":{"State":4}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	april := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	mai := time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+	juni := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	bieter := func() model.Bieter {
+		m, done := s.ForReading()
+		defer done()
+		return m.Bieter[1]
+	}
+
+	mandat := bieter().Mandat
+	if mandat.Nummer != 1 || mandat.IBAN != "DE02120300000000202051" {
+		t.Fatalf("got mandat %v, expected number 1 for the iban", mandat)
+	}
+
+	// The IBAN was given before the contract, so the mandate is signed with
+	// the contract.
+	if got := mandat.Unterschrieben.Format(time.DateOnly); got != "2025-11-26" {
+		t.Errorf("got signature %s, expected 2025-11-26", got)
+	}
+
+	version := bieter().Version
+	if err := s.Write(func(m model.Model) model.Event { return m.EinzugExport(april, m.Faelligkeit(april), false) }); err != nil {
+		t.Fatalf("export april: %v", err)
+	}
+
+	if got := bieter().Version; got != version+1 {
+		t.Errorf("got version %d after using the mandate, expected %d", got, version+1)
+	}
+
+	mandat = bieter().Mandat
+	if got := mandat.ErsteNutzung.Format(time.DateOnly); got != "2026-04-02" || !mandat.LetzteNutzung.Equal(mandat.ErsteNutzung) {
+		t.Errorf("got use %s to %s, expected 2026-04-02", mandat.ErsteNutzung, mandat.LetzteNutzung)
+	}
+
+	if got := mandat.Sequenz(time.Date(2026, time.May, 4, 0, 0, 0, 0, time.UTC)); got != model.SequenzFolge {
+		t.Errorf("got sequenz %s for may, expected RCUR", got)
+	}
+
+	if !mandat.Abgelaufen(time.Date(2029, time.April, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("mandate did not expire 36 months after the last use")
+	}
+
+	b := bieter()
+	stammdaten := b.Stammdaten
+	stammdaten.IBAN = "de02 1203 0000 0000 2020 51"
+	if err := s.Write(func(m model.Model) model.Event { return m.BieterUpdate(1, b.Version, stammdaten) }); err != nil {
+		t.Fatalf("update with same iban: %v", err)
+	}
+
+	if got := bieter().Mandat.Nummer; got != 1 {
+		t.Errorf("got mandat number %d after writing the same iban, expected 1", got)
+	}
+
+	b = bieter()
+	stammdaten.IBAN = "DE02100100100006820101"
+	if err := s.Write(func(m model.Model) model.Event { return m.BieterUpdate(1, b.Version, stammdaten) }); err != nil {
+		t.Fatalf("update iban: %v", err)
+	}
+
+	mandat = bieter().Mandat
+	if mandat.Nummer != 2 || !mandat.ErsteNutzung.IsZero() {
+		t.Errorf("got mandat %v after changing the iban, expected a new unused mandate", mandat)
+	}
+
+	if err := s.Write(func(m model.Model) model.Event { return m.EinzugExport(mai, m.Faelligkeit(mai), false) }); err != nil {
+		t.Fatalf("export mai: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	einzugMai, _ := m.Einzug(mai)
+	expectMai := []model.Lastschrift{{
+		BietID:       1,
+		Betrag:       8000,
+		Sequenz:      model.SequenzErste,
+		Mandat:       2,
+		Kontoinhaber: "KEIN NAME ANGEGEBEN",
+		IBAN:         "DE02100100100006820101",
+		MandatDatum:  m.Bieter[1].Mandat.Unterschrieben,
+	}}
+	if !slices.Equal(einzugMai.Lastschriften, expectMai) {
+		t.Errorf("got lastschriften %v in mai, expected %v", einzugMai.Lastschriften, expectMai)
+	}
+
+	expectJuni := []model.Lastschrift{{BietID: 1, Betrag: 8000, Sequenz: model.SequenzFolge, Mandat: 2}}
+	if got := m.Lastschriften(juni); !slices.Equal(got, expectJuni) {
+		t.Errorf("got lastschriften %v in juni, expected %v", got, expectJuni)
+	}
+}
+
+func TestAufteilungen(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":3}}
+	{"time":"2023-10-20 18:15:59","type":"bieter-update","payload":{"id":1,"iban":"DE02120300000000202051"}}
+	{"time":"2023-10-20 18:15:59","type":"bieter-update","payload":{"id":2,"iban":"DE02100100100006820101"}}
+	{"time":"2023-10-20 18:15:59","type":"bieter-update","payload":{"id":3,"iban":"DE02100100100006820101","ganz_oder_halb":1}}
+	{"time":"2023-10-20 18:16:00","type":"teilpartner-anfrage","payload":{"bieter":1,"partner":2}}
+	{"time":"2023-10-20 18:16:01","type":"teilpartner-bestaetigen","payload":{"bieter":2,"partner":1}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":1,"gebot":4000}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":2,"gebot":4500}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":3,"gebot":4000}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	aufteilungen := m.Aufteilungen()
+	if len(aufteilungen) != 2 {
+		t.Fatalf("got %d aufteilungen, expected 2: %v", len(aufteilungen), aufteilungen)
+	}
+
+	// The half without a partner has a problem and is sorted first.
+	if a := aufteilungen[0]; a.Bieter.ID != 3 || a.HatPartner() || len(a.Probleme) != 1 {
+		t.Errorf("got aufteilung %v, expected bieter 3 without partner and one problem", a)
+	}
+
+	paar := aufteilungen[1]
+	if paar.Bieter.ID != 1 || paar.Partner.ID != 2 || len(paar.Probleme) != 0 {
+		t.Errorf("got aufteilung %v, expected the pair 1 and 2 without problems", paar)
+	}
+
+	if got := paar.Summe(); got != 8500 {
+		t.Errorf("got summe %s, expected 85 €", got)
+	}
+
+	april := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	expect := []model.Lastschrift{
+		{BietID: 1, Betrag: 4000, Sequenz: model.SequenzErste, Mandat: 1, Teilpartner: 2},
+		{BietID: 2, Betrag: 4500, Sequenz: model.SequenzErste, Mandat: 1, Teilpartner: 1},
+		{BietID: 3, Betrag: 4000, Sequenz: model.SequenzErste, Mandat: 1},
+	}
+	if got := m.Lastschriften(april); !slices.Equal(got, expect) {
+		t.Errorf("got lastschriften %v, expected %v", got, expect)
+	}
+}
+
+func TestZahlungsplaene(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":3}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":2,"jaehrlich":true}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":1,"gebot":8000}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":2,"gebot":9000}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+
+	plaene := m.Zahlungsplaene()
+
+	if got := len(plaene[1]); got != 12 {
+		t.Errorf("got %d termine for the monthly payer, expected 12", got)
+	}
+
+	expect := []model.Termin{{Faelligkeit: m.Saison.ErsteAbbuchung, Betrag: 108000, Sequenz: model.SequenzErste}}
+	if got := plaene[2]; !slices.Equal(got, expect) {
+		t.Errorf("got termine %v for the yearly payer, expected %v", got, expect)
+	}
+
+	if _, ok := plaene[3]; ok {
+		t.Errorf("bieter without gebot has termine")
+	}
+}
+
+func TestAbgleich(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":2}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":3}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":2,"jaehrlich":true}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":1,"gebot":8000}}
+	{"time":"2023-10-20 18:17:00","type":"gebot","payload":{"bieter":2,"gebot":9000}}
+	{"time":"2023-10-20 18:18:00","type":"zahlung","payload":{"id":1,"bieter":1,"betrag":8000,"datum":"2024-04-03T00:00:00Z","zuordnung":"mandat"}}
+	{"time":"2023-10-20 18:18:00","type":"zahlung","payload":{"id":2,"bieter":1,"betrag":8000,"datum":"2024-04-20T00:00:00Z","zuordnung":"iban"}}
+	{"time":"2023-10-20 18:18:00","type":"zahlung","payload":{"id":3,"bieter":2,"betrag":100000,"datum":"2024-04-03T00:00:00Z","zuordnung":"bietnummer"}}
+	{"time":"2023-10-20 18:18:00","type":"zahlung","payload":{"id":4,"bieter":3,"betrag":500,"datum":"2024-04-03T00:00:00Z","zuordnung":"iban"}}
+	{"time":"2023-10-20 18:19:00","type":"ruecklastschrift","payload":{"id":1,"bieter":1,"betrag":8000,"datum":"2024-04-08T00:00:00Z","grund":"AM04"}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, done := s.ForReading()
+	abgleich := m.Abgleich(m.Saison.ErsteAbbuchung)
+	done()
+
+	type kontostand struct {
+		bietID    int
+		differenz model.Gebot
+		zahlungen int
+	}
+	expect := []kontostand{{2, -8000, 1}, {3, 500, 1}, {1, 0, 2}}
+	var got []kontostand
+	for _, k := range abgleich {
+		got = append(got, kontostand{k.Bieter.ID, k.Differenz(), len(k.Zahlungen)})
+	}
+	if !slices.Equal(got, expect) {
+		t.Errorf("got %v, expected %v", got, expect)
+	}
+
+	m, write, done := s.ForWriting()
+	defer done()
+
+	zahlung := m.Zahlungen[3]
+	if err := write(m.ZahlungenErfassen([]model.Zahlung{zahlung})...); err == nil {
+		t.Errorf("importing the same payment twice succeeded, expected an error")
+	}
+}
+
+func TestBieterImport(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"verteilstelle-create","payload":{"id":1,"name":"Villingen","kapazitaet":2}}
+	{"time":"2023-10-20 18:15:58","type":"bieter-create","payload":{"id":1}}
+	{"time":"2023-10-20 18:16:00","type":"bieter-update","payload":{"id":1,"mail":"Anna@example.org","verteilstelle":1}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, write, done := s.ForWriting()
+	defer done()
+
+	if b, ok := m.Duplikat(model.Stammdaten{Mail: "Anna <anna@example.org>"}); !ok || b.ID != 1 {
+		t.Errorf("Duplikat returned %d, %t, expected bieter 1", b.ID, ok)
+	}
+
+	if _, ok := m.Duplikat(model.Stammdaten{IBAN: "DE02120300000000202051"}); ok {
+		t.Errorf("Duplikat found a bieter by an unknown IBAN")
+	}
+
+	stammdaten := []model.Stammdaten{
+		{Vorname: "Bernd", VerteilstelleID: 1, IBAN: "DE02120300000000202051"},
+		{Vorname: "Clara", VerteilstelleID: 1},
+		{Vorname: "Dora"},
+	}
+	if err := write(m.BieterImport(stammdaten)...); err != nil {
+		t.Fatalf("writing import: %v", err)
+	}
+
+	if len(m.Bieter) != 4 {
+		t.Fatalf("got %d bieter, expected 4", len(m.Bieter))
+	}
+
+	warteliste := make(map[string]bool)
+	for _, b := range m.Bieter {
+		warteliste[b.Vorname] = b.Warteliste
+		if b.Vorname == "Bernd" && b.IBAN != "DE02 1203 0000 0000 2020 51" {
+			t.Errorf("got IBAN %q, expected it to be formatted", b.IBAN)
+		}
+	}
+
+	expect := map[string]bool{"": false, "Bernd": false, "Clara": true, "Dora": false}
+	if !maps.Equal(warteliste, expect) {
+		t.Errorf("got warteliste %v, expected %v", warteliste, expect)
+	}
+}
+
+func TestWarteliste(t *testing.T) {
+	zeit := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time {
+		zeit = zeit.Add(time.Minute)
+		return zeit
+	}
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"verteilstelle-create","payload":{"id":1,"name":"Villingen","kapazitaet":1}}
+	{"time":"2023-10-20 18:15:58","type":"verteilstelle-create","payload":{"id":2,"name":"Schwenningen"}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	m, write, done := s.ForWriting()
+	defer done()
+
+	anmelden := func(vorname string) int {
+		t.Helper()
+
+		id, event := m.BieterCreate()
+		if err := write(event); err != nil {
+			t.Fatalf("create %s: %v", vorname, err)
+		}
+
+		if err := write(m.BieterUpdate(id, m.Bieter[id].Version, model.Stammdaten{Vorname: vorname, VerteilstelleID: 1})); err != nil {
+			t.Fatalf("update %s: %v", vorname, err)
+		}
+		return id
+	}
+
+	anna := anmelden("Anna")
+	clara := anmelden("Clara")
+	bernd := anmelden("Bernd")
+
+	if m.Bieter[anna].Warteliste || !m.Bieter[bernd].Warteliste || !m.Bieter[clara].Warteliste {
+		t.Fatalf("expected only Anna to have a place")
+	}
+
+	warteliste := func() []string {
+		var namen []string
+		for _, b := range m.Warteliste(1) {
+			namen = append(namen, b.Vorname)
+		}
+		return namen
+	}
+
+	if got := warteliste(); !slices.Equal(got, []string{"Clara", "Bernd"}) {
+		t.Errorf("got warteliste %v, expected the order of the registration", got)
+	}
+
+	if err := write(m.VerteilstelleZuweisen(clara, 1)); err == nil {
+		t.Errorf("assigning a full verteilstelle succeeded, expected an error")
+	}
+
+	if err := write(m.BieterDelete(anna)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	stammdaten := m.Bieter[bernd].Stammdaten
+	stammdaten.Telefon = "07721 12345"
+	if err := write(m.BieterUpdate(bernd, m.Bieter[bernd].Version, stammdaten)); err != nil {
+		t.Fatalf("editing bernd: %v", err)
+	}
+
+	if !m.Bieter[bernd].Warteliste {
+		t.Errorf("editing a bieter on the warteliste took him from the warteliste")
+	}
+
+	if got := warteliste(); !slices.Equal(got, []string{"Clara", "Bernd"}) {
+		t.Errorf("got warteliste %v after the edit, expected the order to be kept", got)
+	}
+
+	if err := write(m.VerteilstelleZuweisen(clara, 1)); err != nil {
+		t.Fatalf("assigning clara: %v", err)
+	}
+
+	if m.Bieter[clara].Warteliste {
+		t.Errorf("clara is still on the warteliste")
+	}
+
+	stammdaten.VerteilstelleID = 2
+	if err := write(m.BieterUpdate(bernd, m.Bieter[bernd].Version, stammdaten)); err != nil {
+		t.Fatalf("moving bernd: %v", err)
+	}
+
+	if m.Bieter[bernd].Warteliste {
+		t.Errorf("bernd is on the warteliste of a verteilstelle without limit")
+	}
+}
+
+// ohneKonto returns the direct debits without the account of the bieter.
+func ohneKonto(lastschriften []model.Lastschrift) []model.Lastschrift {
+	ohne := make([]model.Lastschrift, len(lastschriften))
+	for i, l := range lastschriften {
+		l.Kontoinhaber = ""
+		l.IBAN = ""
+		l.MandatDatum = time.Time{}
+		ohne[i] = l
+	}
+	return ohne
+}
+
+func TestEreigniszeitNachNeustart(t *testing.T) {
+	// Half past midnight in Germany is the day before in UTC.
+	now := func() time.Time { return time.Date(2026, time.January, 10, 0, 30, 0, 500, time.FixedZone("CET", 3600)) }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"verteilstelle-create","payload":{"id":1,"name":"Villingen","kapazitaet":1}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	for _, iban := range []string{"DE02120300000000202051", "DE02100100100006820101"} {
+		if err := s.Write(func(m model.Model) model.Event {
+			_, event := m.BieterCreateStammdaten(model.Stammdaten{IBAN: iban, VerteilstelleID: 1})
+			return event
+		}); err != nil {
+			t.Fatalf("create bieter: %v", err)
+		}
+	}
+
+	live, done := s.ForReading()
+	done()
+
+	neustart, err := sticky.New(sticky.NewMemoryDB(dbContent.Content), model.New(), model.GetEvent)
+	if err != nil {
+		t.Fatalf("loading the database again: %v", err)
+	}
+
+	geladen, done := neustart.ForReading()
+	defer done()
+
+	if len(live.Bieter) != 2 {
+		t.Fatalf("got %d bieter, expected 2", len(live.Bieter))
+	}
+
+	for id, b := range live.Bieter {
+		other := geladen.Bieter[id]
+		if !b.Mandat.Unterschrieben.Equal(other.Mandat.Unterschrieben) {
+			t.Errorf("bieter %d: got signature %s after loading, expected %s", id, other.Mandat.Unterschrieben, b.Mandat.Unterschrieben)
+		}
+
+		if !b.WartelisteSeit.Equal(other.WartelisteSeit) {
+			t.Errorf("bieter %d: got warteliste since %s after loading, expected %s", id, other.WartelisteSeit, b.WartelisteSeit)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Ruecklastschrift is a direct debit, that was returned by the bank of the
+// bieter.
+type Ruecklastschrift struct {
+	ID     int   `json:"id"`
+	BietID int   `json:"bieter"`
+	Betrag Gebot `json:"betrag"`
+
+	// Datum is the booking date of the return.
+	Datum time.Time `json:"datum"`
+
+	// Grund is the ISO 20022 reason code, for example "AM04".
+	Grund string `json:"grund"`
+
+	EndToEndID string `json:"end_to_end_id,omitempty"`
+
+	// Monat is the einzug, that contained the direct debit. It is zero, if
+	// the return could only be matched by the mandate reference.
+	Monat time.Time `json:"monat,omitzero"`
+
+	// Sequenz is the sequence type of the returned debit.
+	Sequenz Sequenz `json:"sequenz,omitempty"`
+
+	// Nachholen is true, if the amount should be collected again with the
+	// next einzug.
+	Nachholen bool `json:"nachholen,omitempty"`
+
+	// NachgeholtIn is the month of the einzug, that collected the amount
+	// again.
+	NachgeholtIn time.Time `json:"nachgeholt_in,omitzero"`
+}
+
+// Offen returns true, if the amount was not collected again.
+func (r Ruecklastschrift) Offen() bool {
+	return r.NachgeholtIn.IsZero()
+}
+
+// GrundText returns a description of the reason code.
+func (r Ruecklastschrift) GrundText() string {
+	if text, ok := rueckgabeGruende[r.Grund]; ok {
+		return text
+	}
+	return "Unbekannter Grund"
+}
+
+// rueckgabeGruende are the descriptions of the common return reason codes.
+var rueckgabeGruende = map[string]string{
+	"AC01": "IBAN fehlerhaft",
+	"AC04": "Konto erloschen",
+	"AC06": "Konto gesperrt",
+	"AC13": "Konto ist kein Verbraucherkonto",
+	"AG01": "Lastschrift auf dem Konto nicht erlaubt",
+	"AG02": "Ungültiger Zahlungsvorgang",
+	"AM04": "Konto nicht gedeckt",
+	"AM05": "Doppelte Lastschrift",
+	"BE05": "Gläubiger unbekannt",
+	"FF01": "Ungültiges Dateiformat",
+	"MD01": "Kein gültiges Mandat",
+	"MD02": "Mandatsdaten fehlen oder sind falsch",
+	"MD06": "Widerspruch durch den Zahlungspflichtigen",
+	"MD07": "Zahlungspflichtiger verstorben",
+	"MS02": "Widerspruch ohne Angabe von Gründen",
+	"MS03": "Keine Angabe von Gründen",
+	"RC01": "BIC fehlerhaft",
+	"RR01": "Regulatorische Gründe",
+	"RR02": "Regulatorische Gründe",
+	"RR03": "Regulatorische Gründe",
+	"RR04": "Regulatorische Gründe",
+	"SL01": "Besondere Dienstleistung der Bank",
+}
+
+// RuecklastschriftenListe returns all returns, the newest first.
+func (m Model) RuecklastschriftenListe() []Ruecklastschrift {
+	liste := make([]Ruecklastschrift, 0, len(m.Ruecklastschriften))
+	for _, r := range m.Ruecklastschriften {
+		liste = append(liste, r)
+	}
+	slices.SortFunc(liste, func(a, b Ruecklastschrift) int {
+		return cmp.Or(
+			b.Datum.Compare(a.Datum),
+			cmp.Compare(b.ID, a.ID),
+		)
+	})
+	return liste
+}
+
+// OffeneRuecklastschriften returns the ids of the bieter, that have a return,
+// that was not collected again.
+func (m Model) OffeneRuecklastschriften() map[int]bool {
+	offen := make(map[int]bool)
+	for _, r := range m.Ruecklastschriften {
+		if r.Offen() {
+			offen[r.BietID] = true
+		}
+	}
+	return offen
+}
+
+// Gleich returns true, if both returns are the same booking of the bank.
+func (r Ruecklastschrift) Gleich(other Ruecklastschrift) bool {
+	return r.BietID == other.BietID &&
+		r.Betrag == other.Betrag &&
+		r.Datum.Equal(other.Datum) &&
+		r.EndToEndID == other.EndToEndID
+}
+
+// RuecklastschriftBekannt returns true, if the return was already imported.
+func (m Model) RuecklastschriftBekannt(r Ruecklastschrift) bool {
+	for _, known := range m.Ruecklastschriften {
+		if known.Gleich(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuecklastschriftenErfassen records returned direct debits.
+//
+// The returns get consecutive ids, so all events can be written together.
+func (m Model) RuecklastschriftenErfassen(ruecklastschriften []Ruecklastschrift) []Event {
+	var maxID int
+	for id := range m.Ruecklastschriften {
+		maxID = max(maxID, id)
+	}
+
+	events := make([]Event, len(ruecklastschriften))
+	for i, r := range ruecklastschriften {
+		maxID++
+		r.ID = maxID
+		r.Nachholen = false
+		r.NachgeholtIn = time.Time{}
+		events[i] = eventRuecklastschrift{r}
+	}
+	return events
+}
+
+// RuecklastschriftNachholen marks a return to be collected again with the
+// next einzug.
+func (m Model) RuecklastschriftNachholen(id int, nachholen bool) Event {
+	return eventRuecklastschriftNachholen{ID: id, Nachholen: nachholen}
+}
+
+// nachholungen returns the direct debits, that collect returns again in the
+// einzug of a month.
+//
+// Returns, that were already collected in this month are included, so the
+// month can be exported again.
+func (m Model) nachholungen(monat time.Time) []Lastschrift {
+	var lastschriften []Lastschrift
+	for _, r := range m.Ruecklastschriften {
+		if !r.Nachholen || !(r.Offen() || r.NachgeholtIn.Equal(monat)) || r.Monat.Equal(monat) {
+			continue
+		}
+
+		bieter, ok := m.Bieter[r.BietID]
+		if !ok {
+			continue
+		}
+
+		lastschriften = append(lastschriften, Lastschrift{
+			BietID:           r.BietID,
+			Betrag:           r.Betrag,
+			Sequenz:          m.sequenz(bieter, monat),
+			Mandat:           bieter.Mandat.Nummer,
+			Ruecklastschrift: r.ID,
+		})
+	}
+	return lastschriften
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Saison is the gemüsejahr, for which the anteile are given out.
+type Saison struct {
+	Name string `json:"name"`
+
+	// Start and Ende are the first and the last month of the saison. Only
+	// the year and the month are used.
+	Start time.Time `json:"start"`
+	Ende  time.Time `json:"ende"`
+
+	// ErsteAbbuchung is the date of the first debit.
+	ErsteAbbuchung time.Time `json:"erste_abbuchung"`
+
+	// MandatDatum is the date, when the SEPA mandates are signed.
+	MandatDatum time.Time `json:"mandat_datum"`
+}
+
+// defaultSaison is the saison that was hard coded, before it was saved in the
+// model.
+func defaultSaison() Saison {
+	return Saison{
+		Name:           "2026/2027",
+		Start:          time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Ende:           time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC),
+		ErsteAbbuchung: time.Date(2026, time.April, 2, 0, 0, 0, 0, time.UTC),
+		MandatDatum:    time.Date(2025, time.November, 26, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+var monatsnamen = [...]string{
+	"Januar",
+	"Februar",
+	"März",
+	"April",
+	"Mai",
+	"Juni",
+	"Juli",
+	"August",
+	"September",
+	"Oktober",
+	"November",
+	"Dezember",
+}
+
+// Monatsname returns the german name of the month and the year, for example
+// "April 2026".
+func Monatsname(t time.Time) string {
+	return fmt.Sprintf("%s %d", monatsnamen[t.Month()-1], t.Year())
+}
+
+// StartMonat returns the first month of the saison, for example "April 2026".
+func (s Saison) StartMonat() string {
+	return Monatsname(s.Start)
+}
+
+// EndeMonat returns the last month of the saison, for example "März 2027".
+func (s Saison) EndeMonat() string {
+	return Monatsname(s.Ende)
+}
+
+// Zeitraum returns the first and the last month of the saison.
+func (s Saison) Zeitraum() string {
+	return fmt.Sprintf("%s – %s", s.StartMonat(), s.EndeMonat())
+}
+
+// Kuerzel returns a short form of the saison, for example "26.27".
+func (s Saison) Kuerzel() string {
+	return fmt.Sprintf("%02d.%02d", s.Start.Year()%100, s.Ende.Year()%100)
+}
+
+// Monate returns the first day of every month of the saison.
+func (s Saison) Monate() []time.Time {
+	var monate []time.Time
+	monat := time.Date(s.Start.Year(), s.Start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	ende := time.Date(s.Ende.Year(), s.Ende.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !monat.After(ende) {
+		monate = append(monate, monat)
+		monat = monat.AddDate(0, 1, 0)
+	}
+	return monate
+}
+
+// SetSaison sets the gemüsejahr.
+func (m Model) SetSaison(saison Saison) Event {
+	return eventSaison{saison}
+}
+
+// Folgejahr returns the saison one year after this saison.
+func (s Saison) Folgejahr() Saison {
+	folge := Saison{
+		Start:          s.Start.AddDate(1, 0, 0),
+		Ende:           s.Ende.AddDate(1, 0, 0),
+		ErsteAbbuchung: s.ErsteAbbuchung.AddDate(1, 0, 0),
+		MandatDatum:    s.MandatDatum.AddDate(1, 0, 0),
+	}
+
+	folge.Name = fmt.Sprintf("%d/%d", folge.Start.Year(), folge.Ende.Year())
+	if folge.Start.Year() == folge.Ende.Year() {
+		folge.Name = fmt.Sprintf("%d", folge.Start.Year())
+	}
+	return folge
+}
@@ -0,0 +1,377 @@
+package model
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jbub/banking/iban"
+)
+
+// Teilpartner returns the confirmed teilpartner of a bieter.
+func (m Model) Teilpartner(bieter Bieter) (Bieter, bool) {
+	if bieter.TeilpartnerID == 0 {
+		return Bieter{}, false
+	}
+	partner, ok := m.Bieter[bieter.TeilpartnerID]
+	return partner, ok
+}
+
+// TeilpartnerAnfragen returns all bieter, that asked the given bieter to share
+// an anteil.
+func (m Model) TeilpartnerAnfragen(bietID int) []Bieter {
+	var anfragen []Bieter
+	for _, bieter := range m.Bieter {
+		if bieter.TeilpartnerAnfrage == bietID {
+			anfragen = append(anfragen, bieter)
+		}
+	}
+	slices.SortFunc(anfragen, func(a, b Bieter) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return anfragen
+}
+
+// BieterFuerEinladung returns the bieter for a bietnummer or an
+// einladungscode.
+func (m Model) BieterFuerEinladung(einladung string) (Bieter, bool) {
+	einladung = strings.ToUpper(strings.TrimSpace(einladung))
+	if einladung == "" {
+		return Bieter{}, false
+	}
+
+	if id, err := strconv.Atoi(einladung); err == nil {
+		bieter, ok := m.Bieter[id]
+		return bieter, ok
+	}
+
+	for _, bieter := range m.Bieter {
+		if bieter.Einladungscode == einladung {
+			return bieter, true
+		}
+	}
+	return Bieter{}, false
+}
+
+// Anteile returns the number of anteile of all bieter. A confirmed pair of
+// teilpartner counts as one anteil.
+func (m Model) Anteile() float64 {
+	var anteile float64
+	for _, bieter := range m.Bieter {
+		anteile += bieter.GanzOderHalb.Anteil()
+	}
+	return anteile
+}
+
+// Paare returns the number of confirmed teilpartner pairs.
+func (m Model) Paare() int {
+	var paare int
+	for _, bieter := range m.Bieter {
+		if bieter.TeilpartnerID != 0 {
+			paare++
+		}
+	}
+	return paare / 2
+}
+
+// TeilpartnerAnfrage asks another bieter to share an anteil.
+func (m Model) TeilpartnerAnfrage(bietID int, partnerID int) Event {
+	return eventTeilpartnerAnfrage{BietID: bietID, PartnerID: partnerID}
+}
+
+// TeilpartnerBestaetigen confirms the request of another bieter to share an
+// anteil.
+func (m Model) TeilpartnerBestaetigen(bietID int, partnerID int) Event {
+	return eventTeilpartnerBestaetigen{BietID: bietID, PartnerID: partnerID}
+}
+
+// TeilpartnerLoesen removes a request or a confirmed pair between two bieter.
+func (m Model) TeilpartnerLoesen(bietID int, partnerID int) Event {
+	return eventTeilpartnerLoesen{BietID: bietID, PartnerID: partnerID}
+}
+
+// EinladungscodeErzeugen creates a code, that a bieter can give to another
+// person to be linked as teilpartner.
+func (m Model) EinladungscodeErzeugen(bietID int) Event {
+	const chars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	var b strings.Builder
+	for range 8 {
+		b.WriteByte(chars[rand.Intn(len(chars))])
+	}
+	return eventEinladungscode{BietID: bietID, Code: b.String()}
+}
+
+// TeilpartnerZuordnen links two bieter as teilpartner without a request. It
+// is used by the admin to accept a Vorschlag.
+func (m Model) TeilpartnerZuordnen(bietID int, partnerID int) Event {
+	return eventTeilpartnerZuordnen{BietID: bietID, PartnerID: partnerID}
+}
+
+// Vorschlag is a proposed pair of bieter, that could share an anteil.
+type Vorschlag struct {
+	Bieter  Bieter
+	Partner Bieter
+}
+
+// GleicheVerteilstelle returns true, if both bieter use the same
+// verteilstelle.
+func (v Vorschlag) GleicheVerteilstelle() bool {
+	return v.Bieter.VerteilstelleID == v.Partner.VerteilstelleID
+}
+
+// sucheTeilpartner returns all bieter, that are looking for a teilpartner or
+// would share an anteil, but are not linked yet.
+func (m Model) sucheTeilpartner() []Bieter {
+	var suchende []Bieter
+	for _, bieter := range m.Bieter {
+		if bieter.TeilpartnerID != 0 || bieter.Warteliste {
+			continue
+		}
+
+		if bieter.GanzOderHalb == HalberAnteilSuche || bieter.GanzOderHalb == HalberAnteilMoeglich {
+			suchende = append(suchende, bieter)
+		}
+	}
+	slices.SortFunc(suchende, func(a, b Bieter) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return suchende
+}
+
+// TeilpartnerVorschlaege proposes pairs of bieter, that could share an anteil.
+//
+// Every pair contains at least one bieter with HalberAnteilSuche. Pairs at the
+// same verteilstelle are preferred, then pairs of two bieter, that both need a
+// teilpartner.
+func (m Model) TeilpartnerVorschlaege() []Vorschlag {
+	suchende := m.sucheTeilpartner()
+
+	type kandidat struct {
+		vorschlag Vorschlag
+		punkte    int
+	}
+
+	var kandidaten []kandidat
+	for i, a := range suchende {
+		for _, b := range suchende[i+1:] {
+			if a.GanzOderHalb != HalberAnteilSuche && b.GanzOderHalb != HalberAnteilSuche {
+				continue
+			}
+
+			vorschlag := Vorschlag{Bieter: a, Partner: b}
+			var punkte int
+			if vorschlag.GleicheVerteilstelle() {
+				punkte += 4
+			}
+			if a.GanzOderHalb == HalberAnteilSuche {
+				punkte++
+			}
+			if b.GanzOderHalb == HalberAnteilSuche {
+				punkte++
+			}
+			kandidaten = append(kandidaten, kandidat{vorschlag: vorschlag, punkte: punkte})
+		}
+	}
+
+	// The sort is stable, so pairs with the same points are ordered by the
+	// bietnummer.
+	slices.SortStableFunc(kandidaten, func(a, b kandidat) int {
+		return cmp.Compare(b.punkte, a.punkte)
+	})
+
+	vergeben := make(map[int]bool)
+	var vorschlaege []Vorschlag
+	for _, k := range kandidaten {
+		if vergeben[k.vorschlag.Bieter.ID] || vergeben[k.vorschlag.Partner.ID] {
+			continue
+		}
+		vergeben[k.vorschlag.Bieter.ID] = true
+		vergeben[k.vorschlag.Partner.ID] = true
+		vorschlaege = append(vorschlaege, k.vorschlag)
+	}
+	return vorschlaege
+}
+
+// OhneVorschlag returns the bieter with HalberAnteilSuche, that are not part
+// of a Vorschlag.
+func (m Model) OhneVorschlag(vorschlaege []Vorschlag) []Bieter {
+	vergeben := make(map[int]bool)
+	for _, v := range vorschlaege {
+		vergeben[v.Bieter.ID] = true
+		vergeben[v.Partner.ID] = true
+	}
+
+	var ohne []Bieter
+	for _, bieter := range m.sucheTeilpartner() {
+		if bieter.GanzOderHalb == HalberAnteilSuche && !vergeben[bieter.ID] {
+			ohne = append(ohne, bieter)
+		}
+	}
+	return ohne
+}
+
+// teilpartnerVerbinden links two bieter as teilpartner.
+func teilpartnerVerbinden(model Model, bietID int, partnerID int) Model {
+	bieter := model.Bieter[bietID]
+	partner := model.Bieter[partnerID]
+
+	bieter.TeilpartnerID = partner.ID
+	bieter.TeilpartnerAnfrage = 0
+	bieter.GanzOderHalb = HalberAnteil
+	bieter.Teilpartner = partner.Name()
+
+	partner.TeilpartnerID = bieter.ID
+	partner.TeilpartnerAnfrage = 0
+	partner.GanzOderHalb = HalberAnteil
+	partner.Teilpartner = bieter.Name()
+
+	model.setBieter(bieter)
+	model.setBieter(partner)
+
+	// Other requests to one of them can not be confirmed anymore.
+	for _, id := range []int{bieter.ID, partner.ID} {
+		for _, other := range model.TeilpartnerAnfragen(id) {
+			other.TeilpartnerAnfrage = 0
+			model.setBieter(other)
+		}
+	}
+	return model
+}
+
+// teilpartnerTrennen removes the link between a bieter and his teilpartner.
+func teilpartnerTrennen(model Model, bietID int) Model {
+	bieter, ok := model.Bieter[bietID]
+	if !ok || bieter.TeilpartnerID == 0 {
+		return model
+	}
+
+	if partner, ok := model.Bieter[bieter.TeilpartnerID]; ok {
+		partner.TeilpartnerID = 0
+		model.setBieter(partner)
+	}
+
+	bieter.TeilpartnerID = 0
+	model.setBieter(bieter)
+	return model
+}
+
+// Aufteilung is a half anteil with the payments of both teilpartner.
+type Aufteilung struct {
+	Bieter Bieter
+
+	// Partner is the confirmed teilpartner. It is empty, if the bieter has
+	// no teilpartner.
+	Partner Bieter
+
+	// Probleme are errors, that have to be fixed before the einzug.
+	Probleme []string
+
+	// Hinweise are unusual values, that are allowed.
+	Hinweise []string
+}
+
+// HatPartner returns true, if the half anteil has a confirmed teilpartner.
+func (a Aufteilung) HatPartner() bool {
+	return a.Partner.ID != 0
+}
+
+// Summe returns the monthly payment for the whole anteil.
+func (a Aufteilung) Summe() Gebot {
+	return a.Bieter.Gebot + a.Partner.Gebot
+}
+
+// Aufteilungen pairs the bieter with a half anteil and checks, that both
+// halves are paid.
+//
+// Every pair is returned once with the lower bietnummer as Bieter. Bieter
+// with a half anteil but without a teilpartner are returned alone, since
+// nobody pays the other half.
+func (m Model) Aufteilungen() []Aufteilung {
+	var aufteilungen []Aufteilung
+	for _, bieter := range m.Bieter {
+		if !bieter.GanzOderHalb.Halb() {
+			continue
+		}
+
+		partner, ok := m.Teilpartner(bieter)
+		if ok && partner.ID < bieter.ID {
+			continue
+		}
+
+		if !ok && bieter.Warteliste {
+			continue
+		}
+
+		aufteilungen = append(aufteilungen, m.aufteilungPruefen(bieter, partner))
+	}
+
+	slices.SortFunc(aufteilungen, func(a, b Aufteilung) int {
+		return cmp.Or(
+			cmp.Compare(len(b.Probleme), len(a.Probleme)),
+			cmp.Compare(a.Bieter.ID, b.Bieter.ID),
+		)
+	})
+	return aufteilungen
+}
+
+func (m Model) aufteilungPruefen(bieter Bieter, partner Bieter) Aufteilung {
+	a := Aufteilung{Bieter: bieter, Partner: partner}
+	haelften := []Bieter{bieter}
+	if a.HatPartner() {
+		haelften = append(haelften, partner)
+	} else {
+		a.Probleme = append(a.Probleme, "Kein bestätigter Teilpartner. Die andere Hälfte des Anteils wird von niemandem bezahlt.")
+	}
+
+	for _, b := range haelften {
+		if b.Gebot.Empty() {
+			a.Probleme = append(a.Probleme, fmt.Sprintf("%s hat kein Gebot abgegeben.", bieterBezeichnung(b)))
+		}
+
+		if _, err := iban.Parse(b.IBANTrimed()); err != nil {
+			a.Probleme = append(a.Probleme, fmt.Sprintf("%s hat keine gültige IBAN.", bieterBezeichnung(b)))
+		}
+	}
+
+	if !a.HatPartner() {
+		return a
+	}
+
+	if bieter.Warteliste != partner.Warteliste {
+		wartet := bieter
+		if partner.Warteliste {
+			wartet = partner
+		}
+		a.Probleme = append(a.Probleme, fmt.Sprintf("%s steht auf der Warteliste.", bieterBezeichnung(wartet)))
+	}
+
+	if bieter.VerteilstelleID != partner.VerteilstelleID {
+		a.Probleme = append(a.Probleme, "Die Teilpartner haben unterschiedliche Verteilstellen.")
+	}
+
+	if minimum := m.Gebotsregeln.Ganz.Minimum; minimum > 0 && !bieter.Gebot.Empty() && !partner.Gebot.Empty() && a.Summe() < minimum {
+		a.Probleme = append(a.Probleme, fmt.Sprintf("Zusammen zahlen die Teilpartner %s. Das ist weniger als das Mindestgebot von %s für einen ganzen Anteil.", a.Summe(), minimum))
+	}
+
+	if bieter.IBANTrimed() != "" && strings.EqualFold(bieter.IBANTrimed(), partner.IBANTrimed()) {
+		a.Hinweise = append(a.Hinweise, "Beide Hälften werden vom selben Konto abgebucht.")
+	}
+
+	if bieter.Jaehrlich != partner.Jaehrlich {
+		a.Hinweise = append(a.Hinweise, "Ein Teilpartner zahlt jährlich, der andere monatlich.")
+	}
+
+	return a
+}
+
+// bieterBezeichnung returns the name of the bieter or the bietnummer, if
+// the bieter has no name.
+func bieterBezeichnung(b Bieter) string {
+	if name := b.Name(); name != "" {
+		return name
+	}
+	return fmt.Sprintf("Bieter %d", b.ID)
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Verteilstelle is a place where the bieter pick up there anteil.
+type Verteilstelle struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Adresse   string    `json:"adresse"`
+	Abholtag  Wochentag `json:"abholtag"`
+	Abholzeit string    `json:"abholzeit"`
+	Kontakt   string    `json:"kontakt"`
+	Inaktiv   bool      `json:"inaktiv"`
+
+	// Kapazitaet is the number of anteile, that can be picked up at the
+	// verteilstelle. 0 means, that there is no limit.
+	Kapazitaet int `json:"kapazitaet"`
+}
+
+func (v Verteilstelle) String() string {
+	if v.Name == "" {
+		return "-"
+	}
+	return v.Name
+}
+
+// Abholung returns a text, when the anteil can be picked up.
+func (v Verteilstelle) Abholung() string {
+	if v.Abholtag == WochentagNone {
+		return v.Abholzeit
+	}
+	return strings.TrimSpace(v.Abholtag.String() + " " + v.Abholzeit)
+}
+
+// legacyVerteilstellen are the verteilstellen that where hard coded before
+// they could be managed in the model. Old events reference them by there id.
+var legacyVerteilstellen = map[int]string{
+	1: "Villingen",
+	2: "Schwenningen",
+	3: "Überauchen",
+}
+
+// migrateLegacyVerteilstelle creates a verteilstelle for an id of the old
+// hard coded values, if it does not exist.
+func migrateLegacyVerteilstelle(model Model, id int) Model {
+	if _, ok := model.Verteilstellen[id]; ok {
+		return model
+	}
+
+	name, ok := legacyVerteilstellen[id]
+	if !ok {
+		return model
+	}
+
+	model.Verteilstellen[id] = Verteilstelle{ID: id, Name: name}
+	return model
+}
+
+// Verteilstelle returns the verteilstelle of a bieter.
+func (m Model) Verteilstelle(bieter Bieter) Verteilstelle {
+	return m.Verteilstellen[bieter.VerteilstelleID]
+}
+
+// Belegung returns the number of anteile at a verteilstelle. Bieter on the
+// warteliste are not counted.
+func (m Model) Belegung(verteilstelleID int) float64 {
+	var belegung float64
+	for _, bieter := range m.Bieter {
+		if bieter.VerteilstelleID == verteilstelleID && !bieter.Warteliste {
+			belegung += bieter.GanzOderHalb.Anteil()
+		}
+	}
+	return belegung
+}
+
+// HatPlatz tells, if there is enough room at the verteilstelle of the bieter.
+//
+// The bieter itself is not counted as already there.
+func (m Model) HatPlatz(bieter Bieter) bool {
+	verteilstelle, ok := m.Verteilstellen[bieter.VerteilstelleID]
+	if !ok || verteilstelle.Kapazitaet == 0 {
+		return true
+	}
+
+	belegung := m.Belegung(verteilstelle.ID)
+	if old, ok := m.Bieter[bieter.ID]; ok && old.VerteilstelleID == verteilstelle.ID && !old.Warteliste {
+		belegung -= old.GanzOderHalb.Anteil()
+	}
+
+	return belegung+bieter.GanzOderHalb.Anteil() <= float64(verteilstelle.Kapazitaet)
+}
+
+// brauchtPlatz tells, if the new values of a bieter need more room at a
+// verteilstelle then before.
+//
+// A bieter on the warteliste, that stays at the same verteilstelle, does not
+// need room. He can only leave the warteliste with VerteilstelleZuweisen.
+func (m Model) brauchtPlatz(bieter Bieter) bool {
+	old := m.Bieter[bieter.ID]
+	return old.VerteilstelleID != bieter.VerteilstelleID ||
+		old.GanzOderHalb.Anteil() < bieter.GanzOderHalb.Anteil()
+}
+
+// Warteliste returns the bieter on the warteliste of a verteilstelle in the
+// order they were put there.
+func (m Model) Warteliste(verteilstelleID int) []Bieter {
+	var bieter []Bieter
+	for _, b := range m.Bieter {
+		if b.VerteilstelleID == verteilstelleID && b.Warteliste {
+			bieter = append(bieter, b)
+		}
+	}
+	slices.SortFunc(bieter, func(a, b Bieter) int {
+		return cmp.Or(
+			a.WartelisteSeit.Compare(b.WartelisteSeit),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return bieter
+}
+
+// VerteilstelleZuweisen assigns a bieter to a verteilstelle and removes him
+// from the warteliste.
+func (m Model) VerteilstelleZuweisen(bietID int, verteilstelleID int) Event {
+	return eventVerteilstelleZuweisen{BietID: bietID, VerteilstelleID: verteilstelleID}
+}
+
+// VerteilstellenListe returns all verteilstellen ordered by name.
+func (m Model) VerteilstellenListe() []Verteilstelle {
+	verteilstellen := make([]Verteilstelle, 0, len(m.Verteilstellen))
+	for _, v := range m.Verteilstellen {
+		verteilstellen = append(verteilstellen, v)
+	}
+	slices.SortFunc(verteilstellen, func(a, b Verteilstelle) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return verteilstellen
+}
+
+// VerteilstelleCreate creates a new verteilstelle.
+func (m Model) VerteilstelleCreate(verteilstelle Verteilstelle) (int, Event) {
+	var maxID int
+	for id := range m.Verteilstellen {
+		maxID = max(maxID, id)
+	}
+	verteilstelle.ID = maxID + 1
+	verteilstelle.Inaktiv = false
+	return verteilstelle.ID, eventVerteilstelleCreate{verteilstelle}
+}
+
+// VerteilstelleUpdate updates the data of a verteilstelle.
+func (m Model) VerteilstelleUpdate(verteilstelle Verteilstelle) Event {
+	return eventVerteilstelleUpdate{verteilstelle}
+}
+
+// VerteilstelleSetAktiv activates or deactivates a verteilstelle.
+func (m Model) VerteilstelleSetAktiv(id int, aktiv bool) Event {
+	return eventVerteilstelleAktiv{ID: id, Aktiv: aktiv}
+}
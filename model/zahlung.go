@@ -0,0 +1,178 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Zuordnung is the way, a payment was matched to a bieter.
+type Zuordnung string
+
+// Ways to match a payment to a bieter.
+const (
+	ZuordnungMandat     Zuordnung = "mandat"
+	ZuordnungBietnummer Zuordnung = "bietnummer"
+	ZuordnungIBAN       Zuordnung = "iban"
+)
+
+// Text returns the description of the matching.
+func (z Zuordnung) Text() string {
+	switch z {
+	case ZuordnungMandat:
+		return "Mandatsreferenz"
+	case ZuordnungBietnummer:
+		return "Bietnummer im Verwendungszweck"
+	case ZuordnungIBAN:
+		return "IBAN"
+	default:
+		return string(z)
+	}
+}
+
+// Zahlung is a credit on the account of the association from a bank
+// statement, that was matched to a bieter.
+//
+// Collected direct debits are payments as well as bank transfers.
+type Zahlung struct {
+	ID     int   `json:"id"`
+	BietID int   `json:"bieter"`
+	Betrag Gebot `json:"betrag"`
+
+	// Datum is the booking date of the credit.
+	Datum time.Time `json:"datum"`
+
+	// Referenz is the end-to-end id or the reference of the bank.
+	Referenz         string `json:"referenz,omitempty"`
+	Verwendungszweck string `json:"verwendungszweck,omitempty"`
+
+	// Name and IBAN are the account, the money came from.
+	Name string `json:"name,omitempty"`
+	IBAN string `json:"iban,omitempty"`
+
+	Zuordnung Zuordnung `json:"zuordnung"`
+}
+
+// Gleich returns true, if both payments are the same booking of the bank.
+func (z Zahlung) Gleich(other Zahlung) bool {
+	return z.BietID == other.BietID &&
+		z.Betrag == other.Betrag &&
+		z.Datum.Equal(other.Datum) &&
+		z.Referenz == other.Referenz &&
+		z.Verwendungszweck == other.Verwendungszweck &&
+		z.IBAN == other.IBAN
+}
+
+// ZahlungBekannt returns true, if the payment was already imported.
+func (m Model) ZahlungBekannt(z Zahlung) bool {
+	for _, known := range m.Zahlungen {
+		if known.Gleich(z) {
+			return true
+		}
+	}
+	return false
+}
+
+// ZahlungenErfassen records payments from a bank statement.
+//
+// The payments get consecutive ids, so all events can be written together.
+func (m Model) ZahlungenErfassen(zahlungen []Zahlung) []Event {
+	var maxID int
+	for id := range m.Zahlungen {
+		maxID = max(maxID, id)
+	}
+
+	events := make([]Event, len(zahlungen))
+	for i, z := range zahlungen {
+		maxID++
+		z.ID = maxID
+		events[i] = eventZahlung{z}
+	}
+	return events
+}
+
+// Kontostand compares the payments of a bieter with the amount, that is due.
+type Kontostand struct {
+	Bieter Bieter
+
+	// Faellig is the sum of all debits of the saison, that are due.
+	Faellig Gebot
+
+	// Bezahlt is the sum of the payments minus the returned debits.
+	Bezahlt Gebot
+
+	Zahlungen []Zahlung
+}
+
+// Differenz returns the over-payment. It is negative, if the bieter paid too
+// little.
+func (k Kontostand) Differenz() Gebot {
+	return k.Bezahlt - k.Faellig
+}
+
+// Abgleich compares the payments of every bieter with the debits, that are
+// due on the day.
+//
+// Debits, that collect a return again, are not due a second time. The
+// returned debits are subtracted from the payments. The list contains bieter
+// with a gebot or a payment, the biggest differences first.
+func (m Model) Abgleich(stichtag time.Time) []Kontostand {
+	konten := make(map[int]*Kontostand)
+	konto := func(bietID int) *Kontostand {
+		k, ok := konten[bietID]
+		if !ok {
+			k = &Kontostand{Bieter: m.Bieter[bietID]}
+			konten[bietID] = k
+		}
+		return k
+	}
+
+	for bietID, termine := range m.Zahlungsplaene() {
+		for _, termin := range termine {
+			if termin.Nachholung || termin.Faelligkeit.After(stichtag) {
+				continue
+			}
+			konto(bietID).Faellig += termin.Betrag
+		}
+	}
+
+	for _, z := range m.Zahlungen {
+		k := konto(z.BietID)
+		k.Bezahlt += z.Betrag
+		k.Zahlungen = append(k.Zahlungen, z)
+	}
+
+	for _, r := range m.Ruecklastschriften {
+		konto(r.BietID).Bezahlt -= r.Betrag
+	}
+
+	abgleich := make([]Kontostand, 0, len(konten))
+	for bietID, k := range konten {
+		if _, ok := m.Bieter[bietID]; !ok {
+			continue
+		}
+
+		slices.SortFunc(k.Zahlungen, func(a, b Zahlung) int {
+			return cmp.Or(
+				a.Datum.Compare(b.Datum),
+				cmp.Compare(a.ID, b.ID),
+			)
+		})
+		abgleich = append(abgleich, *k)
+	}
+
+	slices.SortFunc(abgleich, func(a, b Kontostand) int {
+		return cmp.Or(
+			cmp.Compare(abs(b.Differenz()), abs(a.Differenz())),
+			cmp.Compare(a.Bieter.ID, b.Bieter.ID),
+		)
+	})
+	return abgleich
+}
+
+func abs(g Gebot) Gebot {
+	if g < 0 {
+		return -g
+	}
+	return g
+}
@@ -15,7 +15,9 @@ import (
 	"github.com/johnfercher/maroto/v2/pkg/consts/align"
 	"github.com/johnfercher/maroto/v2/pkg/consts/extension"
 	"github.com/johnfercher/maroto/v2/pkg/consts/fontstyle"
+	"github.com/johnfercher/maroto/v2/pkg/core"
 	"github.com/johnfercher/maroto/v2/pkg/props"
+	"github.com/ostcar/bietrunde/config"
 	"github.com/ostcar/bietrunde/model"
 )
 
@@ -23,18 +25,18 @@ import (
 var headerImage []byte
 
 // Bietervertrag creates the bietervertrag pdf for a bieter
-func Bietervertrag(domain string, bieter model.Bieter) ([]byte, error) {
+func Bietervertrag(domain string, bieter model.Bieter, verteilstelle model.Verteilstelle, saison model.Saison, glaeubiger config.Glaeubiger) ([]byte, error) {
 	cfg := marotoConfig.NewBuilder().
 		Build()
 
 	m := maroto.New(cfg)
 
-	abbuchungText := "Die Abbuchung erfolgt jeweils am ersten Werktag eines Monats von April 2026 bis März 2027."
+	abbuchungText := fmt.Sprintf("Die Abbuchung erfolgt jeweils am ersten Werktag eines Monats von %s bis %s.", saison.StartMonat(), saison.EndeMonat())
 	abbuchungBetrag := bieter.Gebot.String()
 	monatlicherBetrag := bieter.Gebot.String()
 	var abstandBetrag float64 = 5
 	if bieter.Jaehrlich {
-		abbuchungText = "Die Abbuchung erfolgt am 1. Werktag im April 2026."
+		abbuchungText = fmt.Sprintf("Die Abbuchung erfolgt am %s.", saison.ErsteAbbuchung.Format("02.01.2006"))
 		abbuchungBetrag = (bieter.Gebot * 12).String()
 	}
 
@@ -44,17 +46,20 @@ func Bietervertrag(domain string, bieter model.Bieter) ([]byte, error) {
 		abbuchungBetrag = ""
 	}
 
-	adresse := strings.ReplaceAll(bieter.Adresse, "\n", ", ")
+	adresse := strings.ReplaceAll(bieter.Anschrift(), "\n", ", ")
+
+	verteilstelleText := fmt.Sprintf(`Ich hole meinen Anteil in der Verteilstelle %s ab.`, verteilstelle.String())
+	if verteilstelle.Adresse != "" {
+		verteilstelleText = fmt.Sprintf(`Ich hole meinen Anteil in der Verteilstelle %s (%s) ab.`, verteilstelle.String(), strings.ReplaceAll(verteilstelle.Adresse, "\n", ", "))
+	}
+	if verteilstelle.Abholung() != "" {
+		verteilstelleText += fmt.Sprintf(" Abholung: %s.", verteilstelle.Abholung())
+	}
 
 	m.AddRows(
 		// Header
 		row.New(20).Add(
-			col.New(6).Add(
-				text.New("Solidarische Landwirtschaft Baarfood e. V", props.Text{Size: 10, Top: 3.5}),
-				text.New("Neckarstrasse 120", props.Text{Size: 10, Top: 7}),
-				text.New("78056 Villingen-Schwenningen", props.Text{Size: 10, Top: 10.5}),
-				text.New("www.baarfood.de", props.Text{Size: 10, Top: 14}),
-			),
+			col.New(6).Add(kopfzeilen(glaeubiger)...),
 			code.NewQrCol(3, fmt.Sprintf("%s?biet-id=%d", domain, bieter.ID)),
 			image.NewFromBytesCol(3, headerImage, extension.Png, props.Rect{Center: true}),
 		),
@@ -69,9 +74,9 @@ func Bietervertrag(domain string, bieter model.Bieter) ([]byte, error) {
 
 		// Vertragstext
 		text.NewRow(10, fmt.Sprintf(`
-			Ich, %s (E-Mail: %s ), bin Mitglied im Verein Solidarische Landwirtschaft Baarfood e.V.
-			und möchte im Gemüsejahr 2026/2027 (April 2026 – März 2027) einen Gemüseanteil beziehen.`,
-			bieter.Name(), bieter.Mail),
+			Ich, %s (E-Mail: %s ), bin Mitglied im Verein %s
+			und möchte im Gemüsejahr %s (%s) einen Gemüseanteil beziehen.`,
+			bieter.Name(), bieter.Mail, glaeubiger.Verein, saison.Name, saison.Zeitraum()),
 		),
 		text.NewRow(35,
 			`Der Gemüsevertrag gilt im oben genannten Zeitraum, daher für 12 Monate.
@@ -86,9 +91,7 @@ func Bietervertrag(domain string, bieter model.Bieter) ([]byte, error) {
 			oben genannten monatlichen finanziellen Beitrag für die verbleibenden Monate übernimmt.
 			Erst ab Abschluss des neuen Gemüsevertrages erfolgt der Lastschrifteinzug von diesem neuen Mitglied.`,
 		),
-		text.NewRow(abstandBetrag,
-			fmt.Sprintf(`Ich hole meinen Anteil in der Verteilstelle in %s ab.`, bieter.Verteilstelle.String()),
-		),
+		text.NewRow(abstandBetrag, verteilstelleText),
 		text.NewRow(abstandBetrag,
 			fmt.Sprintf("Mein Beitrag für den Gemüseanteil monatlich: %s", monatlicherBetrag),
 			props.Text{Style: fontstyle.Bold},
@@ -118,15 +121,16 @@ func Bietervertrag(domain string, bieter model.Bieter) ([]byte, error) {
 			Align: align.Center,
 			Top:   5,
 		}),
-		text.NewRow(5, `Gläubiger-Identifikationsnummer: DE62ZZZ00001997635`),
-		text.NewRow(5, fmt.Sprintf(`Mandatsreferenz: 25%d`, bieter.ID)),
+		text.NewRow(5, fmt.Sprintf(`Gläubiger-Identifikationsnummer: %s`, glaeubiger.GlaeubigerID)),
+		text.NewRow(5, fmt.Sprintf(`Mandatsreferenz: %s`, glaeubiger.Mandatsreferenz(bieter.ID, bieter.Mandat.Nummer))),
 		text.NewRow(abstandBetrag, abbuchungText),
 		text.NewRow(abstandBetrag, fmt.Sprintf("Der Betrag lautet: %s", abbuchungBetrag), props.Text{Style: fontstyle.Bold}),
-		text.NewRow(15,
-			`Ich ermächtige den Verein Solidarische Landwirtschaft Baarfood e.V.
+		text.NewRow(15, fmt.Sprintf(
+			`Ich ermächtige den Verein %s
 			Lastschriften von meinem Konto einzuziehen. Zugleich weise ich mein
-			Kreditinstitut an, die von Solidarische Landwirtschaft Baarfood e.V.
+			Kreditinstitut an, die von %s
 			auf mein Konto gezogenen Lastschriften einzulösen.`,
+			glaeubiger.Verein, glaeubiger.Verein),
 		),
 		text.NewRow(10,
 			`Ich kann innerhalb von acht Wochen, beginnend mit dem Belastungsdatum,
@@ -158,3 +162,93 @@ func Bietervertrag(domain string, bieter model.Bieter) ([]byte, error) {
 
 	return document.GetBytes(), nil
 }
+
+// kopfzeilen returns the name and the address of the glaeubiger for the
+// header.
+func kopfzeilen(glaeubiger config.Glaeubiger) []core.Component {
+	zeilen := []core.Component{
+		text.New(glaeubiger.Verein, props.Text{Size: 10, Top: 3.5}),
+	}
+	for i, zeile := range glaeubiger.Anschrift {
+		zeilen = append(zeilen, text.New(zeile, props.Text{Size: 10, Top: 7 + 3.5*float64(i)}))
+	}
+	return zeilen
+}
+
+// Vorabankuendigung creates the pre-notification of the direct debits of a
+// saison for a bieter.
+func Vorabankuendigung(bieter model.Bieter, termine []model.Termin, saison model.Saison, glaeubiger config.Glaeubiger) ([]byte, error) {
+	cfg := marotoConfig.NewBuilder().
+		Build()
+
+	m := maroto.New(cfg)
+
+	var summe model.Gebot
+	for _, termin := range termine {
+		summe += termin.Betrag
+	}
+
+	m.AddRows(
+		row.New(20).Add(
+			col.New(9).Add(kopfzeilen(glaeubiger)...),
+			image.NewFromBytesCol(3, headerImage, extension.Png, props.Rect{Center: true}),
+		),
+
+		text.NewRow(15, "Vorabankündigung der SEPA-Lastschriften", props.Text{
+			Size:  14,
+			Style: fontstyle.Bold,
+			Align: align.Center,
+			Top:   5,
+		}),
+
+		text.NewRow(10, fmt.Sprintf("Hallo %s,", bieter.Name())),
+		text.NewRow(15, fmt.Sprintf(
+			"für deinen Gemüseanteil im Gemüsejahr %s (%s) buchen wir die folgenden Beträge per SEPA-Lastschrift von deinem Konto ab. "+
+				"Fällt ein Termin auf ein Wochenende oder einen Feiertag, erfolgt die Abbuchung am nächsten Bankarbeitstag.",
+			saison.Name, saison.Zeitraum()),
+		),
+
+		text.NewRow(5, fmt.Sprintf("Kontoinhaber: %s", bieter.ShowKontoinhaber())),
+		text.NewRow(5, fmt.Sprintf("IBAN: %s", ibanMaskiert(bieter.IBANTrimed()))),
+		text.NewRow(5, fmt.Sprintf("Mandatsreferenz: %s", glaeubiger.Mandatsreferenz(bieter.ID, bieter.Mandat.Nummer))),
+		text.NewRow(5, fmt.Sprintf("Gläubiger-Identifikationsnummer: %s", glaeubiger.GlaeubigerID)),
+		text.NewRow(10, fmt.Sprintf("Verwendungszweck: %s", glaeubiger.Verwendungszweck(saison.Kuerzel()))),
+
+		terminZeile("Fälligkeit", "Betrag", fontstyle.Bold),
+	)
+
+	for _, termin := range termine {
+		m.AddRows(terminZeile(termin.Faelligkeit.Format("02.01.2006"), termin.Betrag.String(), fontstyle.Normal))
+	}
+
+	m.AddRows(
+		terminZeile("Summe", summe.String(), fontstyle.Bold),
+		text.NewRow(15,
+			`Ändert sich dein Gebot oder deine Bankverbindung, bekommst du eine neue Vorabankündigung.`,
+			props.Text{Top: 5},
+		),
+	)
+
+	document, err := m.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("generate: %w", err)
+	}
+
+	return document.GetBytes(), nil
+}
+
+func terminZeile(datum string, betrag string, style fontstyle.Type) core.Row {
+	return row.New(6).Add(
+		text.NewCol(4, datum, props.Text{Style: style}),
+		text.NewCol(3, betrag, props.Text{Style: style, Align: align.Right}),
+	)
+}
+
+// ibanMaskiert hides the middle of an iban, for example
+// "DE02 **** **** **** 2051".
+func ibanMaskiert(iban string) string {
+	if len(iban) < 8 {
+		return iban
+	}
+	return iban[:4] + " **** **** **** " + iban[len(iban)-4:]
+}
@@ -0,0 +1,403 @@
+package web
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/ostcar/bietrunde/model"
+	"github.com/ostcar/sticky"
+)
+
+// registerAPI registers the handlers of the json api.
+func (s server) registerAPI(router *mux.Router) {
+	router.Handle("/api/v1/bieter", handleAPIError(s.apiAuth(s.handleAPIBieterList)))
+	router.Handle("/api/v1/bieter/{id:[0-9]+}", handleAPIError(s.apiAuth(s.handleAPIBieter)))
+	router.Handle("/api/v1/bieter/{id:[0-9]+}/anwesend", handleAPIError(s.apiAuth(s.handleAPIAnwesend)))
+	router.Handle("/api/v1/bieter/{id:[0-9]+}/gebot", handleAPIError(s.apiAuth(s.handleAPIGebot)))
+	router.Handle("/api/v1/state", handleAPIError(s.apiAuth(s.handleAPIState)))
+	router.PathPrefix("/api/").Handler(handleAPIError(func(w http.ResponseWriter, r *http.Request) error {
+		return apiError{http.StatusNotFound, "not_found", "Unbekannter Endpunkt"}
+	}))
+}
+
+// apiError is an error, that is returned to the client.
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func apiMethodNotAllowed(allowed ...string) apiError {
+	return apiError{http.StatusMethodNotAllowed, "method_not_allowed", "Erlaubt sind " + strings.Join(allowed, ", ")}
+}
+
+func apiBieterNotFound(id int) apiError {
+	return apiError{http.StatusNotFound, "not_found", fmt.Sprintf("Bieter %d existiert nicht", id)}
+}
+
+// handleAPIError writes errors as json.
+//
+// Validation errors of events are returned with the status 422 and the code
+// "validation". Unknown errors are logged and returned with the status 500.
+func handleAPIError(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := handler(w, r)
+		if err == nil {
+			return
+		}
+
+		var errAPI apiError
+		var errValidation sticky.ValidationError
+		switch {
+		case errors.As(err, &errAPI):
+		case errors.As(err, &errValidation):
+			errAPI = apiError{http.StatusUnprocessableEntity, "validation", errValidation.String()}
+		default:
+			log.Printf("Error: %v", err)
+			errAPI = apiError{http.StatusInternalServerError, "internal", "Unbekannter Fehler"}
+		}
+
+		if errAPI.Status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="bietrunde"`)
+		}
+
+		if err := writeJSON(w, errAPI.Status, struct {
+			Error apiError `json:"error"`
+		}{errAPI}); err != nil {
+			log.Printf("Error: writing api error: %v", err)
+		}
+	}
+}
+
+// apiAuth only calls the handler, if the request has one of the api tokens
+// from the config as bearer token.
+func (s server) apiAuth(next func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && token != "" {
+			for _, apiToken := range s.cfg.APITokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) == 1 {
+					return next(w, r)
+				}
+			}
+		}
+
+		return apiError{http.StatusUnauthorized, "unauthorized", "Ungültiger oder fehlender API-Token"}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// readJSON decodes the body of the request. Unknown fields are an error, so
+// that typos in field names are not ignored.
+func readJSON(r *http.Request, v any) error {
+	return decodeJSON(http.MaxBytesReader(nil, r.Body, 1<<20), v)
+}
+
+// decodeJSON decodes json and returns an apiError, if it is invalid.
+func decodeJSON(reader io.Reader, v any) error {
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return apiError{http.StatusBadRequest, "bad_request", fmt.Sprintf("Ungültiges JSON: %v", err)}
+	}
+	return nil
+}
+
+// apiBieter is a bieter in the json api. Amounts are in cent.
+type apiBieter struct {
+	ID int `json:"id"`
+	model.Stammdaten
+	Gebot         model.Gebot `json:"gebot"`
+	Jahresbeitrag model.Gebot `json:"jahresbeitrag"`
+	Anwesend      bool        `json:"anwesend"`
+	CanSelfEdit   bool        `json:"can_edit"`
+	Warteliste    bool        `json:"warteliste"`
+	TeilpartnerID int         `json:"teilpartner_id,omitempty"`
+
+	// Version has to be sent with an update to detect concurrent changes.
+	Version int `json:"version"`
+
+	// InvalidFields are the missing or wrong stammdaten by the name of the
+	// field.
+	InvalidFields map[string]string `json:"invalid_fields,omitempty"`
+}
+
+func newAPIBieter(b model.Bieter) apiBieter {
+	return apiBieter{
+		ID:            b.ID,
+		Stammdaten:    b.Stammdaten,
+		Gebot:         b.Gebot,
+		Jahresbeitrag: b.Jahresbeitrag(),
+		Anwesend:      b.Anwesend,
+		CanSelfEdit:   b.CanSelfEdit,
+		Warteliste:    b.Warteliste,
+		TeilpartnerID: b.TeilpartnerID,
+		Version:       b.Version,
+		InvalidFields: b.InvalidFields(),
+	}
+}
+
+func apiBieterID(r *http.Request) int {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return id
+}
+
+func (s server) handleAPIBieterList(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		m, done := s.model.ForReading()
+		defer done()
+
+		bieter := make([]apiBieter, 0, len(m.Bieter))
+		for _, b := range m.Bieter {
+			bieter = append(bieter, newAPIBieter(b))
+		}
+		slices.SortFunc(bieter, func(a, b apiBieter) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+
+		return writeJSON(w, http.StatusOK, struct {
+			Bieter []apiBieter `json:"bieter"`
+		}{bieter})
+
+	case http.MethodPost:
+		var stammdaten model.Stammdaten
+		if err := readJSON(r, &stammdaten); err != nil {
+			return err
+		}
+
+		m, write, done := s.model.ForWriting()
+		defer done()
+
+		bietID, event := m.BieterCreateStammdaten(stammdaten)
+		if err := write(event); err != nil {
+			return err
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/bieter/%d", bietID))
+		return writeJSON(w, http.StatusCreated, newAPIBieter(m.Bieter[bietID]))
+
+	default:
+		return apiMethodNotAllowed(http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleAPIBieter reads, updates or deletes a bieter.
+//
+// An update only changes the stammdaten, that are sent. If the version is
+// sent, the update fails, when the bieter was changed in the meantime.
+func (s server) handleAPIBieter(w http.ResponseWriter, r *http.Request) error {
+	bietID := apiBieterID(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		m, done := s.model.ForReading()
+		defer done()
+
+		bieter, ok := m.Bieter[bietID]
+		if !ok {
+			return apiBieterNotFound(bietID)
+		}
+		return writeJSON(w, http.StatusOK, newAPIBieter(bieter))
+
+	case http.MethodPatch:
+		type bieterUpdate struct {
+			Version *int `json:"version"`
+			model.Stammdaten
+		}
+
+		// The body is read and checked before the lock is taken, so a slow
+		// client does not block the other writes.
+		var body json.RawMessage
+		if err := readJSON(r, &body); err != nil {
+			return err
+		}
+
+		var update bieterUpdate
+		if err := decodeJSON(bytes.NewReader(body), &update); err != nil {
+			return err
+		}
+
+		m, write, done := s.model.ForWriting()
+		defer done()
+
+		bieter, ok := m.Bieter[bietID]
+		if !ok {
+			return apiBieterNotFound(bietID)
+		}
+
+		// Decoding the body again on the current stammdaten only changes the
+		// fields that were sent.
+		update.Stammdaten = bieter.Stammdaten
+		if err := decodeJSON(bytes.NewReader(body), &update); err != nil {
+			return err
+		}
+
+		version := bieter.Version
+		if update.Version != nil {
+			version = *update.Version
+		}
+
+		if err := write(m.BieterUpdate(bietID, version, update.Stammdaten)); err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusOK, newAPIBieter(m.Bieter[bietID]))
+
+	case http.MethodDelete:
+		m, write, done := s.model.ForWriting()
+		defer done()
+
+		if _, ok := m.Bieter[bietID]; !ok {
+			return apiBieterNotFound(bietID)
+		}
+
+		if err := write(m.BieterDelete(bietID)); err != nil {
+			return err
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+
+	default:
+		return apiMethodNotAllowed(http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+// handleAPIAnwesend sets, if a bieter is present at the bidding round.
+func (s server) handleAPIAnwesend(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPut {
+		return apiMethodNotAllowed(http.MethodPut)
+	}
+
+	var body struct {
+		Anwesend bool `json:"anwesend"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		return err
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	bietID := apiBieterID(r)
+	if _, ok := m.Bieter[bietID]; !ok {
+		return apiBieterNotFound(bietID)
+	}
+
+	if err := write(m.BieterSetAnwesend(bietID, body.Anwesend)); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, newAPIBieter(m.Bieter[bietID]))
+}
+
+// handleAPIGebot submits the gebot of a bieter in cent.
+//
+// The same rules as on the page of the bieter apply. A gebot can only be
+// submitted, while the service is in the offer state and the bieter is
+// present. A gebot outside of the soft limit of the gebotsregeln has to be
+// confirmed with "bestaetigt".
+func (s server) handleAPIGebot(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return apiMethodNotAllowed(http.MethodPost)
+	}
+
+	var body struct {
+		Gebot      *model.Gebot `json:"gebot"`
+		Bestaetigt bool         `json:"bestaetigt"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		return err
+	}
+
+	if body.Gebot == nil {
+		return apiError{http.StatusBadRequest, "bad_request", "Das Gebot fehlt"}
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	bietID := apiBieterID(r)
+	bieter, ok := m.Bieter[bietID]
+	if !ok {
+		return apiBieterNotFound(bietID)
+	}
+
+	if m.State != model.StateOffer {
+		return apiError{http.StatusConflict, "state", "Aktuell kann kein Gebot abgegeben werden"}
+	}
+
+	if !bieter.Anwesend {
+		return apiError{http.StatusConflict, "abwesend", "Der Bieter muss anwesend sein um ein Gebot abzugeben"}
+	}
+
+	if warnung := m.GebotWarnung(bietID, *body.Gebot); warnung != "" && !body.Bestaetigt {
+		return apiError{http.StatusUnprocessableEntity, "bestaetigung", warnung}
+	}
+
+	if err := write(m.SetGebot(bietID, *body.Gebot, body.Bestaetigt)); err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, newAPIBieter(m.Bieter[bietID]))
+}
+
+// apiState is the state of the service in the json api.
+type apiState struct {
+	State string `json:"state"`
+	Name  string `json:"name"`
+}
+
+func (s server) handleAPIState(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		m, done := s.model.ForReading()
+		defer done()
+
+		return writeJSON(w, http.StatusOK, apiState{m.State.ToAttr(), m.State.String()})
+
+	case http.MethodPut:
+		var body apiState
+		if err := readJSON(r, &body); err != nil {
+			return err
+		}
+
+		state := model.StateFromAttr(body.State)
+		if state == model.StateInvalid {
+			var erlaubt []string
+			for _, st := range model.States() {
+				erlaubt = append(erlaubt, st.ToAttr())
+			}
+			return apiError{http.StatusBadRequest, "bad_request", fmt.Sprintf("Unbekannter Status %q, erlaubt sind %s", body.State, strings.Join(erlaubt, ", "))}
+		}
+
+		m, write, done := s.model.ForWriting()
+		defer done()
+
+		if err := write(m.SetState(state)); err != nil {
+			return err
+		}
+		return writeJSON(w, http.StatusOK, apiState{state.ToAttr(), state.String()})
+
+	default:
+		return apiMethodNotAllowed(http.MethodGet, http.MethodPut)
+	}
+}
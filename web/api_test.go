@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+	"github.com/ostcar/sticky"
+)
+
+func TestAPI(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	dbContent := sticky.NewMemoryDB(`
+	{"time":"2023-10-20 18:15:58","type":"verteilstelle-create","payload":{"id":1,"name":"Villingen"}}
+	`)
+	s, err := sticky.New(dbContent, model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	const token = "0123456789abcdef"
+	srv := newServer(config.Config{APITokens: map[string]string{"test": token}}, s)
+
+	request := func(t *testing.T, method, path, body string, expectStatus int) map[string]any {
+		t.Helper()
+
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		srv.ServeHTTP(resp, req)
+
+		if resp.Code != expectStatus {
+			t.Fatalf("%s %s: got status %d, expected %d: %s", method, path, resp.Code, expectStatus, resp.Body)
+		}
+
+		var data map[string]any
+		if resp.Code != http.StatusNoContent {
+			decoder := json.NewDecoder(resp.Body)
+			decoder.UseNumber()
+			if err := decoder.Decode(&data); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+		}
+		return data
+	}
+
+	t.Run("without token", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		srv.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/bieter", nil))
+
+		if resp.Code != http.StatusUnauthorized {
+			t.Errorf("got status %d, expected 401", resp.Code)
+		}
+	})
+
+	bieter := request(t, "POST", "/api/v1/bieter", `{"vorname":"Anna","verteilstelle":1}`, http.StatusCreated)
+	path := "/api/v1/bieter/" + bieter["id"].(json.Number).String()
+
+	t.Run("update", func(t *testing.T) {
+		got := request(t, "PATCH", path, `{"nachname":"Schmidt"}`, http.StatusOK)
+		if got["vorname"] != "Anna" || got["nachname"] != "Schmidt" {
+			t.Errorf("got %v, expected the vorname to be kept", got)
+		}
+
+		got = request(t, "PATCH", path, `{"version":1,"nachname":"Müller"}`, http.StatusUnprocessableEntity)
+		if apiErr := got["error"].(map[string]any); apiErr["code"] != "validation" || apiErr["message"] == "" {
+			t.Errorf("got error %v, expected a validation error", apiErr)
+		}
+
+		request(t, "PATCH", path, `{"vornmae":"Anna"}`, http.StatusBadRequest)
+	})
+
+	t.Run("slow update", func(t *testing.T) {
+		body, bodyW := io.Pipe()
+		lesen := make(chan struct{})
+		req := httptest.NewRequest("PATCH", path, &ersterLesezugriff{Reader: body, signal: lesen})
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		finished := make(chan struct{})
+		go func() {
+			srv.ServeHTTP(resp, req)
+			close(finished)
+		}()
+
+		// Other writes are possible, while the body is read.
+		<-lesen
+		written := make(chan struct{})
+		go func() {
+			_, _, done := s.ForWriting()
+			done()
+			close(written)
+		}()
+
+		select {
+		case <-written:
+		case <-time.After(time.Second):
+			bodyW.Close()
+			<-finished
+			<-written
+			t.Fatalf("the update blocks other writes while reading the body")
+		}
+
+		bodyW.Write([]byte(`{"ort":"Villingen"}`))
+		bodyW.Close()
+		<-finished
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("got status %d, expected 200: %s", resp.Code, resp.Body)
+		}
+	})
+
+	t.Run("gebot", func(t *testing.T) {
+		request(t, "POST", path+"/gebot", `{"gebot":8000}`, http.StatusConflict)
+
+		if got := request(t, "PUT", "/api/v1/state", `{"state":"offer"}`, http.StatusOK); got["state"] != "offer" {
+			t.Errorf("got state %v, expected offer", got["state"])
+		}
+		request(t, "PUT", "/api/v1/state", `{"state":"unknown"}`, http.StatusBadRequest)
+
+		request(t, "PUT", path+"/anwesend", `{"anwesend":true}`, http.StatusOK)
+		got := request(t, "POST", path+"/gebot", `{"gebot":8000}`, http.StatusOK)
+		if got["gebot"] != json.Number("8000") {
+			t.Errorf("got gebot %v, expected 8000", got["gebot"])
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		request(t, "DELETE", path, "", http.StatusNoContent)
+		request(t, "GET", path, "", http.StatusNotFound)
+
+		if got := request(t, "GET", "/api/v1/bieter", "", http.StatusOK); len(got["bieter"].([]any)) != 0 {
+			t.Errorf("got %v, expected no bieter", got["bieter"])
+		}
+	})
+}
+
+// ersterLesezugriff closes the signal channel, when it is read the first time.
+type ersterLesezugriff struct {
+	io.Reader
+	signal chan struct{}
+	once   sync.Once
+}
+
+func (r *ersterLesezugriff) Read(p []byte) (int, error) {
+	r.once.Do(func() { close(r.signal) })
+	return r.Reader.Read(p)
+}
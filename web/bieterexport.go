@@ -0,0 +1,184 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+// bieterExportSpalten are the columns of the export of all bieter.
+var bieterExportSpalten = []any{
+	"Bietnummer",
+	"Vorname",
+	"Nachname",
+	"E-Mail",
+	"Telefon",
+	"Straße",
+	"Hausnummer",
+	"PLZ",
+	"Ort",
+	"Land",
+	"Mitglied",
+	"Verteilstelle",
+	"Warteliste",
+	"Anteil",
+	"Teilpartner",
+	"Bietnummer Teilpartner",
+	"Kontakt teilen",
+	"Kontoinhaber",
+	"IBAN",
+	"Zahlweise",
+	"Gebot",
+	"Jahresbeitrag",
+	"Mandatsreferenz",
+	"Mandat unterschrieben",
+	"Anwesend",
+	"Selbst bearbeiten",
+}
+
+// bieterTabelle returns the header and one row for every bieter.
+//
+// The values are strings, ints for the ids and float64 for the amounts.
+func bieterTabelle(bieter []model.Bieter, verteilstellen []model.Verteilstelle, glaeubiger config.Glaeubiger) [][]any {
+	namen := make(map[int]string, len(verteilstellen))
+	for _, v := range verteilstellen {
+		namen[v.ID] = v.Name
+	}
+
+	zeilen := [][]any{bieterExportSpalten}
+	for _, b := range bieter {
+		zahlweise := "monatlich"
+		if b.Jaehrlich {
+			zahlweise = "jährlich"
+		}
+
+		var mandat, unterschrieben string
+		if !b.Mandat.Leer() {
+			mandat = glaeubiger.Mandatsreferenz(b.ID, b.Mandat.Nummer)
+			unterschrieben = b.Mandat.Unterschrieben.Format("02.01.2006")
+		}
+
+		var teilpartnerID any = ""
+		if b.TeilpartnerID != 0 {
+			teilpartnerID = b.TeilpartnerID
+		}
+
+		zeilen = append(zeilen, []any{
+			b.ID,
+			b.Vorname,
+			b.Nachname,
+			b.Mail,
+			b.Telefon,
+			b.Strasse,
+			b.Hausnummer,
+			b.PLZ,
+			b.Ort,
+			b.Land,
+			jaNein(b.Mitglied),
+			namen[b.VerteilstelleID],
+			jaNein(b.Warteliste),
+			b.GanzOderHalb.String(),
+			b.Teilpartner,
+			teilpartnerID,
+			jaNein(b.KontaktTeilen),
+			b.Kontoinhaber,
+			b.IBANTrimed(),
+			zahlweise,
+			float64(b.Gebot) / 100,
+			float64(b.Jahresbeitrag()) / 100,
+			mandat,
+			unterschrieben,
+			jaNein(b.Anwesend),
+			jaNein(b.CanSelfEdit),
+		})
+	}
+	return zeilen
+}
+
+func jaNein(b bool) string {
+	if b {
+		return "ja"
+	}
+	return "nein"
+}
+
+// csvTrennzeichen are the delimiters, that can be chosen for the csv export.
+var csvTrennzeichen = map[string]rune{
+	"semikolon": ';',
+	"komma":     ',',
+	"tab":       '\t',
+}
+
+// csvKodierungen are the encodings, that can be chosen for the csv export.
+// Excel needs the byte order mark to detect UTF-8.
+var csvKodierungen = map[string]encoding.Encoding{
+	"utf-8":        encoding.Nop,
+	"utf-8-bom":    encoding.Nop,
+	"windows-1252": charmap.Windows1252,
+}
+
+// writeCSV writes the table as csv.
+//
+// Amounts are written with a decimal comma, if the delimiter is not a comma.
+func writeCSV(w io.Writer, zeilen [][]any, trennzeichen, kodierung string) error {
+	komma, ok := csvTrennzeichen[trennzeichen]
+	if !ok {
+		return fmt.Errorf("unknown delimiter %q", trennzeichen)
+	}
+
+	enc, ok := csvKodierungen[kodierung]
+	if !ok {
+		return fmt.Errorf("unknown encoding %q", kodierung)
+	}
+
+	if kodierung == "utf-8-bom" {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return fmt.Errorf("writing byte order mark: %w", err)
+		}
+	}
+
+	encoder := transform.NewWriter(w, encoding.ReplaceUnsupported(enc.NewEncoder()))
+	csvW := csv.NewWriter(encoder)
+	csvW.Comma = komma
+
+	for _, zeile := range zeilen {
+		felder := make([]string, len(zeile))
+		for i, wert := range zeile {
+			switch v := wert.(type) {
+			case int:
+				felder[i] = strconv.Itoa(v)
+			case float64:
+				felder[i] = strconv.FormatFloat(v, 'f', 2, 64)
+				if komma != ',' {
+					felder[i] = strings.Replace(felder[i], ".", ",", 1)
+				}
+			case string:
+				felder[i] = v
+			default:
+				return fmt.Errorf("unsupported value %T", wert)
+			}
+		}
+
+		if err := csvW.Write(felder); err != nil {
+			return fmt.Errorf("writing csv: %w", err)
+		}
+	}
+
+	csvW.Flush()
+	if err := csvW.Error(); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("encoding csv: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestBieterExport(t *testing.T) {
+	verteilstellen := []model.Verteilstelle{{ID: 1, Name: "Hof"}}
+	bieter := []model.Bieter{
+		{ID: 2, Gebot: 9050, Stammdaten: model.Stammdaten{Vorname: "Jörg", Nachname: "Müller; Sohn", VerteilstelleID: 1, Jaehrlich: true}},
+		{ID: 1},
+	}
+	tabelle := bieterTabelle(bieter, verteilstellen, config.Glaeubiger{MandatsreferenzVorlage: "25{bietnummer}"})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeCSV(&buf, tabelle, "semikolon", "windows-1252"); err != nil {
+			t.Fatalf("writeCSV: %v", err)
+		}
+
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(buf.Bytes())
+		if err != nil {
+			t.Fatalf("decoding windows-1252: %v", err)
+		}
+
+		zeilen := strings.Split(string(decoded), "\n")
+		if len(zeilen) != 4 {
+			t.Fatalf("got %d lines, expected header, 2 bieter and a final line break", len(zeilen))
+		}
+
+		if !strings.HasPrefix(zeilen[0], "Bietnummer;Vorname;Nachname;E-Mail;") {
+			t.Errorf("got header %q", zeilen[0])
+		}
+
+		expect := `2;Jörg;"Müller; Sohn";;;;;;;;nein;Hof;nein;`
+		if !strings.HasPrefix(zeilen[1], expect) || !strings.Contains(zeilen[1], ";jährlich;90,50;1086,00;") {
+			t.Errorf("got line %q", zeilen[1])
+		}
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeXLSX(&buf, "Bieter", tabelle); err != nil {
+			t.Fatalf("writeXLSX: %v", err)
+		}
+
+		r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("reading zip: %v", err)
+		}
+
+		f, err := r.Open("xl/worksheets/sheet1.xml")
+		if err != nil {
+			t.Fatalf("open sheet: %v", err)
+		}
+		sheet, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatalf("read sheet: %v", err)
+		}
+
+		for _, expect := range []string{
+			`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">Bietnummer</t></is></c>`,
+			`<c r="A2"><v>2</v></c>`,
+			`<t xml:space="preserve">Müller; Sohn</t>`,
+			`<c r="U2" s="2"><v>90.5</v></c>`,
+		} {
+			if !strings.Contains(string(sheet), expect) {
+				t.Errorf("%s not found in sheet:\n%s", expect, sheet)
+			}
+		}
+	})
+
+	for index, expect := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxSpalte(index); got != expect {
+			t.Errorf("xlsxSpalte(%d) = %s, expected %s", index, got, expect)
+		}
+	}
+}
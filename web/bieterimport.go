@@ -0,0 +1,256 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ostcar/bietrunde/model"
+	"github.com/ostcar/bietrunde/web/template"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// importFelder are the fields of a bieter, that a column of the csv file can
+// be assigned to. Namen are the column headers, that are assigned
+// automatically.
+var importFelder = []struct {
+	template.ImportFeld
+	namen []string
+}{
+	{template.ImportFeld{Name: "vorname", Titel: "Vorname"}, []string{"vorname", "firstname"}},
+	{template.ImportFeld{Name: "nachname", Titel: "Nachname"}, []string{"nachname", "name", "familienname", "lastname"}},
+	{template.ImportFeld{Name: "mail", Titel: "E-Mail"}, []string{"email", "mail", "emailadresse"}},
+	{template.ImportFeld{Name: "telefon", Titel: "Telefon"}, []string{"telefon", "tel", "telefonnummer", "handy", "mobil"}},
+	{template.ImportFeld{Name: "strasse", Titel: "Straße"}, []string{"strasse"}},
+	{template.ImportFeld{Name: "hausnummer", Titel: "Hausnummer"}, []string{"hausnummer", "hausnr", "nr"}},
+	{template.ImportFeld{Name: "plz", Titel: "PLZ"}, []string{"plz", "postleitzahl"}},
+	{template.ImportFeld{Name: "ort", Titel: "Ort"}, []string{"ort", "stadt", "wohnort"}},
+	{template.ImportFeld{Name: "land", Titel: "Land"}, []string{"land", "laenderkennzeichen"}},
+	{template.ImportFeld{Name: "mitglied", Titel: "Mitglied"}, []string{"mitglied"}},
+	{template.ImportFeld{Name: "verteilstelle", Titel: "Verteilstelle"}, []string{"verteilstelle", "abholstelle"}},
+	{template.ImportFeld{Name: "anteil", Titel: "Anteil (ganz oder halb)"}, []string{"anteil", "ganzoderhalb"}},
+	{template.ImportFeld{Name: "teilpartner", Titel: "Teilpartner"}, []string{"teilpartner"}},
+	{template.ImportFeld{Name: "kontakt_teilen", Titel: "Kontakt teilen"}, []string{"kontaktteilen"}},
+	{template.ImportFeld{Name: "iban", Titel: "IBAN"}, []string{"iban"}},
+	{template.ImportFeld{Name: "kontoinhaber", Titel: "Kontoinhaber"}, []string{"kontoinhaber"}},
+	{template.ImportFeld{Name: "zahlweise", Titel: "Zahlweise (jährlich)"}, []string{"zahlweise", "jaehrlich"}},
+}
+
+// importDekodieren returns the text of an uploaded csv file.
+//
+// Files, that are no valid UTF-8, are read as windows-1252, because that is
+// what Excel uses on german systems.
+func importDekodieren(daten []byte) string {
+	text := strings.TrimPrefix(string(daten), "\ufeff")
+	if utf8.ValidString(text) {
+		return text
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().String(text)
+	if err != nil {
+		return text
+	}
+	return decoded
+}
+
+// readImportCSV reads the rows of the csv text. The delimiter is detected
+// from the first line.
+func readImportCSV(text string) ([][]string, error) {
+	kopf, _, _ := strings.Cut(text, "\n")
+	komma := ';'
+	for _, c := range []rune{'\t', ','} {
+		if strings.Count(kopf, string(c)) > strings.Count(kopf, string(komma)) {
+			komma = c
+		}
+	}
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = komma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	zeilen, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Die CSV-Datei konnte nicht gelesen werden: %w", err)
+	}
+
+	zeilen = slices.DeleteFunc(zeilen, func(zeile []string) bool {
+		return !slices.ContainsFunc(zeile, func(feld string) bool { return strings.TrimSpace(feld) != "" })
+	})
+
+	if len(zeilen) < 2 {
+		return nil, fmt.Errorf("Die CSV-Datei muss eine Kopfzeile und mindestens einen Bieter enthalten")
+	}
+	return zeilen, nil
+}
+
+// importZuordnungRaten assigns the columns to the fields by there header.
+// Columns, that are not known, get an empty string.
+func importZuordnungRaten(kopf []string) []string {
+	zuordnung := make([]string, len(kopf))
+	vergeben := make(map[string]bool)
+	for i, spalte := range kopf {
+		spalte = importNormalisieren(spalte)
+		for _, feld := range importFelder {
+			if !vergeben[feld.Name] && slices.Contains(feld.namen, spalte) {
+				zuordnung[i] = feld.Name
+				vergeben[feld.Name] = true
+				break
+			}
+		}
+	}
+	return zuordnung
+}
+
+// importNormalisieren returns the text in lower case without umlauts and
+// other characters then letters and digits.
+func importNormalisieren(s string) string {
+	s = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss").Replace(strings.ToLower(s))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// importZeilen creates the stammdaten from the csv rows without the header.
+//
+// Values, that can not be read, are returned as hints by the index of the
+// row.
+func importZeilen(zeilen [][]string, zuordnung []string, verteilstellen []model.Verteilstelle) ([]model.Stammdaten, map[int][]string) {
+	hinweise := make(map[int][]string)
+	stammdaten := make([]model.Stammdaten, len(zeilen))
+	for i, zeile := range zeilen {
+		s := &stammdaten[i]
+		for j, wert := range zeile {
+			if j >= len(zuordnung) {
+				break
+			}
+
+			wert = strings.TrimSpace(wert)
+			switch zuordnung[j] {
+			case "vorname":
+				s.Vorname = wert
+			case "nachname":
+				s.Nachname = wert
+			case "mail":
+				s.Mail = wert
+			case "telefon":
+				s.Telefon = wert
+			case "strasse":
+				s.Strasse = wert
+			case "hausnummer":
+				s.Hausnummer = wert
+			case "plz":
+				s.PLZ = wert
+			case "ort":
+				s.Ort = wert
+			case "land":
+				s.Land = strings.ToUpper(wert)
+				if importNormalisieren(wert) == "deutschland" {
+					s.Land = "DE"
+				}
+			case "mitglied":
+				s.Mitglied = importJa(wert)
+			case "verteilstelle":
+				if wert == "" {
+					continue
+				}
+				id, ok := importVerteilstelle(wert, verteilstellen)
+				if !ok {
+					hinweise[i] = append(hinweise[i], fmt.Sprintf("Die Verteilstelle %q ist nicht bekannt.", wert))
+				}
+				s.VerteilstelleID = id
+			case "anteil":
+				anteil, ok := importAnteil(wert)
+				if !ok {
+					hinweise[i] = append(hinweise[i], fmt.Sprintf("Der Anteil %q ist nicht bekannt. Es wird ein ganzer Anteil angenommen.", wert))
+				}
+				s.GanzOderHalb = anteil
+			case "teilpartner":
+				s.Teilpartner = wert
+			case "kontakt_teilen":
+				s.KontaktTeilen = importJa(wert)
+			case "iban":
+				s.IBAN = strings.ToUpper(wert)
+			case "kontoinhaber":
+				s.Kontoinhaber = wert
+			case "zahlweise":
+				s.Jaehrlich = importJa(wert) || importNormalisieren(wert) == "jaehrlich"
+			}
+		}
+
+		if s.Land == "" {
+			s.Land = "DE"
+		}
+	}
+	return stammdaten, hinweise
+}
+
+func importJa(wert string) bool {
+	switch importNormalisieren(wert) {
+	case "ja", "j", "x", "1", "true", "wahr", "yes":
+		return true
+	}
+	return false
+}
+
+// importVerteilstelle finds an active verteilstelle by its name or id.
+func importVerteilstelle(wert string, verteilstellen []model.Verteilstelle) (int, bool) {
+	id, _ := strconv.Atoi(wert)
+	for _, v := range verteilstellen {
+		if v.Inaktiv {
+			continue
+		}
+
+		if v.ID == id || strings.EqualFold(v.Name, wert) {
+			return v.ID, true
+		}
+	}
+	return 0, false
+}
+
+// importAnteil reads the type of the anteil. Confirmed teilpartner can not be
+// imported, so a half anteil is searching for a teilpartner.
+func importAnteil(wert string) (model.GanzOderHalb, bool) {
+	switch importNormalisieren(wert) {
+	case "", "ganz", "ganzer", "1":
+		return model.GanzerAnteil, true
+	case "halb", "halber", "halbsuche", "05":
+		return model.HalberAnteilSuche, true
+	case "halbmoeglich":
+		return model.HalberAnteilMoeglich, true
+	}
+	return model.GanzerAnteil, false
+}
+
+// importVorschau returns the preview of the import.
+//
+// Rows with the same mail address or IBAN as an existing bieter or an
+// earlier row are duplicates.
+func importVorschau(m model.Model, stammdaten []model.Stammdaten, hinweise map[int][]string) []template.ImportZeile {
+	vorschau := make([]template.ImportZeile, len(stammdaten))
+	for i, s := range stammdaten {
+		zeile := template.ImportZeile{
+			Nummer:   i + 1,
+			Bieter:   model.Bieter{Stammdaten: s},
+			Fehler:   model.Bieter{Stammdaten: s}.InvalidFields(),
+			Hinweise: hinweise[i],
+		}
+
+		if b, ok := m.Duplikat(s); ok {
+			zeile.Duplikat = fmt.Sprintf("Bieter %d %s", b.ID, b.Name())
+		} else if j := slices.IndexFunc(stammdaten[:i], func(other model.Stammdaten) bool {
+			return model.GleicheStammdaten(other, s)
+		}); j >= 0 {
+			zeile.Duplikat = fmt.Sprintf("Zeile %d", j+1)
+		}
+
+		vorschau[i] = zeile
+	}
+	return vorschau
+}
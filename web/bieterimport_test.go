@@ -0,0 +1,112 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+	"github.com/ostcar/bietrunde/user"
+	"github.com/ostcar/sticky"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestBieterImport(t *testing.T) {
+	csv := "Vorname;Nachname;E-Mail;Abholstelle;Anteil;Bemerkung\r\n" +
+		"Jörg;Müller;joerg@example.org;Hof;halb;\r\n" +
+		";;;;;\r\n" +
+		"Anna;Schmidt;JOERG@example.org;Markt;viertel;x\r\n"
+
+	datei, err := charmap.Windows1252.NewEncoder().String(csv)
+	if err != nil {
+		t.Fatalf("encoding windows-1252: %v", err)
+	}
+
+	zeilen, err := readImportCSV(importDekodieren([]byte(datei)))
+	if err != nil {
+		t.Fatalf("readImportCSV: %v", err)
+	}
+
+	if len(zeilen) != 3 {
+		t.Fatalf("got %d rows, expected the header and 2 bieter", len(zeilen))
+	}
+
+	zuordnung := importZuordnungRaten(zeilen[0])
+	expect := []string{"vorname", "nachname", "mail", "verteilstelle", "anteil", ""}
+	if !slices.Equal(zuordnung, expect) {
+		t.Errorf("got zuordnung %v, expected %v", zuordnung, expect)
+	}
+
+	verteilstellen := []model.Verteilstelle{{ID: 1, Name: "Hof"}, {ID: 2, Name: "Markt", Inaktiv: true}}
+	stammdaten, hinweise := importZeilen(zeilen[1:], zuordnung, verteilstellen)
+
+	joerg := stammdaten[0]
+	if joerg.Nachname != "Müller" || joerg.VerteilstelleID != 1 || joerg.GanzOderHalb != model.HalberAnteilSuche || joerg.Land != "DE" {
+		t.Errorf("got stammdaten %+v", joerg)
+	}
+
+	if len(hinweise[0]) != 0 || len(hinweise[1]) != 2 {
+		t.Errorf("got hinweise %v, expected 2 for the second row", hinweise)
+	}
+
+	vorschau := importVorschau(model.New(), stammdaten, hinweise)
+	if vorschau[0].Duplikat != "" || vorschau[1].Duplikat != "Zeile 1" {
+		t.Errorf("got duplikate %q and %q, expected the second row to be a duplicate of the first", vorschau[0].Duplikat, vorschau[1].Duplikat)
+	}
+}
+
+func TestBieterImportDoppelt(t *testing.T) {
+	now := func() time.Time { return time.Time{} }
+	s, err := sticky.New(sticky.NewMemoryDB(""), model.New(), model.GetEvent, sticky.WithNow[model.Model](now))
+	if err != nil {
+		t.Fatalf("sticky.New: %v", err)
+	}
+
+	cfg := config.Config{Secret: "secret"}
+	srv := newServer(cfg, s)
+
+	cookie := httptest.NewRecorder()
+	if err := (user.User{IsAdmin: true}).SetCookie(cookie, []byte(cfg.Secret)); err != nil {
+		t.Fatalf("SetCookie: %v", err)
+	}
+
+	form := url.Values{
+		"daten":    {"Vorname;Nachname;E-Mail\r\nJörg;Müller;joerg@example.org\r\n"},
+		"schritt":  {"import"},
+		"spalte-0": {"vorname"},
+		"spalte-1": {"nachname"},
+		"spalte-2": {"mail"},
+		"zeile":    {"1"},
+	}
+
+	importieren := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/import", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookie.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		resp := httptest.NewRecorder()
+		srv.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := importieren(); resp.Code != http.StatusSeeOther {
+		t.Fatalf("first import: got status %d, expected a redirect: %s", resp.Code, resp.Body)
+	}
+
+	resp := importieren()
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "Doppelte Bieter werden nicht importiert") {
+		t.Errorf("second import: got status %d, expected the preview with an error: %s", resp.Code, resp.Body)
+	}
+
+	m, done := s.ForReading()
+	defer done()
+	if len(m.Bieter) != 1 {
+		t.Errorf("got %d bieter, expected the bieter to be imported once", len(m.Bieter))
+	}
+}
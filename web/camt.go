@@ -0,0 +1,379 @@
+package web
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+)
+
+// camtBuchung is one transaction from a camt.053 or camt.054 file.
+type camtBuchung struct {
+	// Gutschrift is true for a credit to the account of the creditor.
+	Gutschrift bool
+
+	// Rueckgabe is true, if the transaction has return information.
+	Rueckgabe bool
+
+	EndToEndID       string
+	Mandatsreferenz  string
+	Betrag           model.Gebot
+	Datum            time.Time
+	Grund            string
+	Verwendungszweck string
+
+	// Name and IBAN are the account of the other party.
+	Name string
+	IBAN string
+
+	// Referenz identifies the transaction. It is the end-to-end id or the
+	// reference of the bank, if the sender gave no end-to-end id.
+	Referenz string
+}
+
+// readCamt reads the transactions from a camt.053 bank statement or a
+// camt.054 notification.
+//
+// The xml namespace is ignored, so all versions of the formats can be read.
+// An entry without transaction details is read as one transaction.
+func readCamt(r io.Reader) ([]camtBuchung, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Die Datei ist keine gültige camt-Datei: %w", err)
+	}
+
+	berichte := append(doc.Notification, doc.Statement...)
+	if len(berichte) == 0 {
+		return nil, fmt.Errorf("Die Datei enthält weder eine camt.053 noch eine camt.054 Nachricht")
+	}
+
+	var buchungen []camtBuchung
+	for _, bericht := range berichte {
+		for _, eintrag := range bericht.Ntry {
+			details := eintrag.TxDtls
+			if len(details) == 0 {
+				details = []camtDetails{{RmtInf: []string{eintrag.AddtlNtryInf}}}
+			}
+
+			for _, tx := range details {
+				betrag, err := tx.betrag(eintrag)
+				if err != nil {
+					return nil, fmt.Errorf("Ungültiger Betrag bei %s: %w", cmp.Or(tx.Refs.EndToEndID, eintrag.AcctSvcrRef), err)
+				}
+
+				gutschrift := strings.TrimSpace(eintrag.CdtDbtInd) == "CRDT"
+				partei, konto := tx.RltdPties.Dbtr, tx.RltdPties.DbtrAcct
+				if !gutschrift {
+					partei, konto = tx.RltdPties.Cdtr, tx.RltdPties.CdtrAcct
+				}
+
+				buchung := camtBuchung{
+					Gutschrift:       gutschrift,
+					Rueckgabe:        tx.RtrInf != nil,
+					EndToEndID:       strings.TrimSpace(tx.Refs.EndToEndID),
+					Mandatsreferenz:  strings.TrimSpace(tx.Refs.MndtID),
+					Betrag:           betrag,
+					Datum:            eintrag.datum(),
+					Verwendungszweck: verwendungszweck(tx.RmtInf),
+					Name:             partei.name(),
+					IBAN:             strings.ToUpper(strings.ReplaceAll(konto, " ", "")),
+					Referenz:         tx.referenz(eintrag),
+				}
+				if tx.RtrInf != nil {
+					buchung.Grund = strings.TrimSpace(tx.RtrInf.Rsn.Cd)
+				}
+				buchungen = append(buchungen, buchung)
+			}
+		}
+	}
+	return buchungen, nil
+}
+
+// verwendungszweck joins the lines of the unstructured remittance
+// information.
+func verwendungszweck(zeilen []string) string {
+	var teile []string
+	for _, zeile := range zeilen {
+		if zeile = strings.TrimSpace(zeile); zeile != "" {
+			teile = append(teile, zeile)
+		}
+	}
+	return strings.Join(teile, " ")
+}
+
+// camtZuordnung is the result of matching the returns of a camt file to the
+// bieter.
+type camtZuordnung struct {
+	Ruecklastschriften []model.Ruecklastschrift
+	Bekannt            int
+	Unbekannt          []camtBuchung
+}
+
+// camtZuordnen matches the returns to the bieter. Other transactions are
+// ignored.
+//
+// A return is matched by the end-to-end id of an exported direct debit. If
+// the id is unknown, for example because the debit was not created by this
+// program, the mandate reference is used.
+func camtZuordnen(m model.Model, buchungen []camtBuchung, glaeubiger config.Glaeubiger) camtZuordnung {
+	lastschriften := camtLastschriften(m, glaeubiger)
+	mandate := camtMandate(m, glaeubiger)
+
+	var zuordnung camtZuordnung
+	for _, rueckgabe := range buchungen {
+		if !rueckgabe.Rueckgabe {
+			continue
+		}
+
+		r := model.Ruecklastschrift{
+			Betrag:     rueckgabe.Betrag,
+			Datum:      rueckgabe.Datum,
+			Grund:      rueckgabe.Grund,
+			EndToEndID: rueckgabe.EndToEndID,
+		}
+
+		if h, ok := lastschriften[rueckgabe.EndToEndID]; ok {
+			r.BietID = h.bietID
+			r.Monat = h.monat
+			r.Sequenz = h.sequenz
+		} else if id, ok := mandate[rueckgabe.Mandatsreferenz]; ok {
+			r.BietID = id
+		} else {
+			zuordnung.Unbekannt = append(zuordnung.Unbekannt, rueckgabe)
+			continue
+		}
+
+		if m.RuecklastschriftBekannt(r) || slices.ContainsFunc(zuordnung.Ruecklastschriften, r.Gleich) {
+			zuordnung.Bekannt++
+			continue
+		}
+
+		zuordnung.Ruecklastschriften = append(zuordnung.Ruecklastschriften, r)
+	}
+	return zuordnung
+}
+
+// camtAbgleich is the result of matching the credits of a camt file to the
+// bieter.
+type camtAbgleich struct {
+	Zahlungen []model.Zahlung
+	Bekannt   int
+	Unbekannt []camtBuchung
+}
+
+// bietnummerMuster finds a bietnummer in the purpose of a payment.
+var bietnummerMuster = regexp.MustCompile(`\b[1-9][0-9]{8}\b`)
+
+// camtZahlungenZuordnen matches the credits to the bieter. Returns and
+// debits are ignored.
+//
+// A credit is matched by the end-to-end id of an exported direct debit or
+// the mandate reference. Bank transfers are matched by a bietnummer in the
+// purpose and at last by the IBAN of the bieter.
+func camtZahlungenZuordnen(m model.Model, buchungen []camtBuchung, glaeubiger config.Glaeubiger) camtAbgleich {
+	lastschriften := camtLastschriften(m, glaeubiger)
+	mandate := camtMandate(m, glaeubiger)
+
+	ibans := make(map[string]int, len(m.Bieter))
+	for id, b := range m.Bieter {
+		if iban := strings.ToUpper(b.IBANTrimed()); iban != "" {
+			ibans[iban] = id
+		}
+	}
+
+	var abgleich camtAbgleich
+	for _, buchung := range buchungen {
+		if !buchung.Gutschrift || buchung.Rueckgabe {
+			continue
+		}
+
+		z := model.Zahlung{
+			Betrag:           buchung.Betrag,
+			Datum:            buchung.Datum,
+			Referenz:         buchung.Referenz,
+			Verwendungszweck: buchung.Verwendungszweck,
+			Name:             buchung.Name,
+			IBAN:             buchung.IBAN,
+		}
+
+		if h, ok := lastschriften[buchung.EndToEndID]; ok {
+			z.BietID, z.Zuordnung = h.bietID, model.ZuordnungMandat
+		} else if id, ok := mandate[buchung.Mandatsreferenz]; ok {
+			z.BietID, z.Zuordnung = id, model.ZuordnungMandat
+		} else if id, ok := camtBietnummer(m, buchung.Verwendungszweck); ok {
+			z.BietID, z.Zuordnung = id, model.ZuordnungBietnummer
+		} else if id, ok := ibans[buchung.IBAN]; ok && buchung.IBAN != "" {
+			z.BietID, z.Zuordnung = id, model.ZuordnungIBAN
+		} else {
+			abgleich.Unbekannt = append(abgleich.Unbekannt, buchung)
+			continue
+		}
+
+		if m.ZahlungBekannt(z) || slices.ContainsFunc(abgleich.Zahlungen, z.Gleich) {
+			abgleich.Bekannt++
+			continue
+		}
+
+		abgleich.Zahlungen = append(abgleich.Zahlungen, z)
+	}
+	return abgleich
+}
+
+// camtBietnummer returns the first bietnummer of an existing bieter in the
+// purpose.
+func camtBietnummer(m model.Model, verwendungszweck string) (int, bool) {
+	for _, nummer := range bietnummerMuster.FindAllString(verwendungszweck, -1) {
+		id, _ := strconv.Atoi(nummer)
+		if _, ok := m.Bieter[id]; ok {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// camtHerkunft is the exported direct debit, that belongs to an end-to-end
+// id.
+type camtHerkunft struct {
+	monat   time.Time
+	sequenz model.Sequenz
+	bietID  int
+}
+
+// camtLastschriften returns the exported direct debits by there end-to-end
+// id.
+func camtLastschriften(m model.Model, glaeubiger config.Glaeubiger) map[string]camtHerkunft {
+	lastschriften := make(map[string]camtHerkunft)
+	for _, einzug := range m.Einzuege {
+		for _, l := range einzug.Lastschriften {
+			lastschriften[endToEndID(l, einzug.Faelligkeit, glaeubiger)] = camtHerkunft{einzug.Monat, l.Sequenz, l.BietID}
+		}
+	}
+	return lastschriften
+}
+
+// camtMandate returns the ids of the bieter by all there mandate references.
+func camtMandate(m model.Model, glaeubiger config.Glaeubiger) map[string]int {
+	mandate := make(map[string]int, len(m.Bieter))
+	for id, b := range m.Bieter {
+		for nummer := range max(b.Mandat.Nummer, 1) {
+			mandate[glaeubiger.Mandatsreferenz(id, nummer+1)] = id
+		}
+	}
+	return mandate
+}
+
+type camtDocument struct {
+	Notification []camtBericht `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+	Statement    []camtBericht `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtBericht struct {
+	Ntry []camtEintrag `xml:"Ntry"`
+}
+
+type camtEintrag struct {
+	Amt          string        `xml:"Amt"`
+	CdtDbtInd    string        `xml:"CdtDbtInd"`
+	BookgDt      camtDatum     `xml:"BookgDt"`
+	ValDt        camtDatum     `xml:"ValDt"`
+	AcctSvcrRef  string        `xml:"AcctSvcrRef"`
+	AddtlNtryInf string        `xml:"AddtlNtryInf"`
+	TxDtls       []camtDetails `xml:"NtryDtls>TxDtls"`
+}
+
+// datum returns the booking date of the entry or the value date, if there
+// is no booking date.
+func (e camtEintrag) datum() time.Time {
+	if datum := e.BookgDt.zeit(); !datum.IsZero() {
+		return datum
+	}
+	return e.ValDt.zeit()
+}
+
+type camtDatum struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+func (d camtDatum) zeit() time.Time {
+	if t, err := time.Parse(time.DateOnly, strings.TrimSpace(d.Dt)); err == nil {
+		return t
+	}
+
+	if len(d.DtTm) >= 10 {
+		if t, err := time.Parse(time.DateOnly, d.DtTm[:10]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+type camtDetails struct {
+	Refs struct {
+		EndToEndID  string `xml:"EndToEndId"`
+		MndtID      string `xml:"MndtId"`
+		AcctSvcrRef string `xml:"AcctSvcrRef"`
+	} `xml:"Refs"`
+	Amt     string `xml:"Amt"`
+	AmtDtls struct {
+		InstdAmt string `xml:"InstdAmt>Amt"`
+		TxAmt    string `xml:"TxAmt>Amt"`
+	} `xml:"AmtDtls"`
+	RltdPties struct {
+		Dbtr     camtPartei `xml:"Dbtr"`
+		DbtrAcct string     `xml:"DbtrAcct>Id>IBAN"`
+		Cdtr     camtPartei `xml:"Cdtr"`
+		CdtrAcct string     `xml:"CdtrAcct>Id>IBAN"`
+	} `xml:"RltdPties"`
+	RmtInf []string `xml:"RmtInf>Ustrd"`
+	RtrInf *struct {
+		Rsn struct {
+			Cd string `xml:"Cd"`
+		} `xml:"Rsn"`
+	} `xml:"RtrInf"`
+}
+
+// camtPartei is the debtor or creditor of a transaction. Newer versions of
+// the format wrap the name in a Pty element.
+type camtPartei struct {
+	Nm    string `xml:"Nm"`
+	PtyNm string `xml:"Pty>Nm"`
+}
+
+func (p camtPartei) name() string {
+	return strings.TrimSpace(cmp.Or(p.Nm, p.PtyNm))
+}
+
+// referenz returns the end-to-end id of the transaction or the reference of
+// the bank.
+func (d camtDetails) referenz(eintrag camtEintrag) string {
+	if id := strings.TrimSpace(d.Refs.EndToEndID); id != "" && id != "NOTPROVIDED" {
+		return id
+	}
+	return strings.TrimSpace(cmp.Or(d.Refs.AcctSvcrRef, eintrag.AcctSvcrRef))
+}
+
+// betrag returns the amount of the original direct debit.
+//
+// The banks add the fees of the return to the transaction amount, so the
+// instructed amount is used, if it exists.
+func (d camtDetails) betrag(eintrag camtEintrag) (model.Gebot, error) {
+	for _, betrag := range []string{d.AmtDtls.InstdAmt, d.AmtDtls.TxAmt, d.Amt} {
+		if betrag = strings.TrimSpace(betrag); betrag != "" {
+			return model.GebotFromString(betrag)
+		}
+	}
+
+	if len(eintrag.TxDtls) <= 1 {
+		return model.GebotFromString(strings.TrimSpace(eintrag.Amt))
+	}
+	return 0, fmt.Errorf("kein Betrag angegeben")
+}
@@ -0,0 +1,206 @@
+package web
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+)
+
+const camt054 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.054.001.02">
+  <BkToCstmrDbtCdtNtfctn>
+    <Ntfctn>
+      <Ntry>
+        <Amt Ccy="EUR">83.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-08</Dt></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>251-20260504-R7</EndToEndId><MndtId>251</MndtId></Refs>
+            <AmtDtls>
+              <InstdAmt><Amt Ccy="EUR">80.00</Amt></InstdAmt>
+              <TxAmt><Amt Ccy="EUR">83.00</Amt></TxAmt>
+            </AmtDtls>
+            <RtrInf><Rsn><Cd>AM04</Cd></Rsn></RtrInf>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">90.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><DtTm>2026-05-09T08:00:00</DtTm></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>NOTPROVIDED</EndToEndId><MndtId>252</MndtId></Refs>
+            <RtrInf><Rsn><Cd>MD06</Cd></Rsn></RtrInf>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">12.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-09</Dt></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>UEBERWEISUNG</EndToEndId></Refs>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+    </Ntfctn>
+  </BkToCstmrDbtCdtNtfctn>
+</Document>`
+
+func TestCamt(t *testing.T) {
+	buchungen, err := readCamt(strings.NewReader(camt054))
+	if err != nil {
+		t.Fatalf("readCamt: %v", err)
+	}
+
+	if len(buchungen) != 3 {
+		t.Fatalf("got %d transactions, expected 3: %v", len(buchungen), buchungen)
+	}
+
+	glaeubiger := config.Glaeubiger{MandatsreferenzVorlage: "25{bietnummer}"}
+	mai := time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	m := model.New()
+	m.Bieter[1] = model.Bieter{ID: 1}
+	m.Bieter[2] = model.Bieter{ID: 2}
+	m.Einzuege["2026-05"] = model.Einzug{
+		Monat:       mai,
+		Faelligkeit: time.Date(2026, time.May, 4, 0, 0, 0, 0, time.UTC),
+		Lastschriften: []model.Lastschrift{
+			{BietID: 1, Betrag: 8000, Sequenz: model.SequenzFolge},
+			{BietID: 1, Betrag: 8000, Sequenz: model.SequenzErste, Ruecklastschrift: 7},
+		},
+	}
+
+	zuordnung := camtZuordnen(m, append(buchungen, buchungen[0]), glaeubiger)
+
+	if zuordnung.Bekannt != 1 || len(zuordnung.Unbekannt) != 0 {
+		t.Errorf("got %d known and %d unknown returns, expected 1 and 0", zuordnung.Bekannt, len(zuordnung.Unbekannt))
+	}
+
+	expect := []model.Ruecklastschrift{
+		{BietID: 1, Betrag: 8000, Datum: time.Date(2026, time.May, 8, 0, 0, 0, 0, time.UTC), Grund: "AM04", EndToEndID: "251-20260504-R7", Monat: mai, Sequenz: model.SequenzErste},
+		{BietID: 2, Betrag: 9050, Datum: time.Date(2026, time.May, 9, 0, 0, 0, 0, time.UTC), Grund: "MD06", EndToEndID: "NOTPROVIDED"},
+	}
+	if len(zuordnung.Ruecklastschriften) != len(expect) {
+		t.Fatalf("got returns %v, expected %v", zuordnung.Ruecklastschriften, expect)
+	}
+	for i, r := range zuordnung.Ruecklastschriften {
+		if r != expect[i] {
+			t.Errorf("got return %v, expected %v", r, expect[i])
+		}
+	}
+}
+
+const camt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Ntry>
+        <Amt Ccy="EUR">160.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-04</Dt></BookgDt>
+        <AcctSvcrRef>SAMMLER-1</AcctSvcrRef>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>251-20260504</EndToEndId><MndtId>251</MndtId></Refs>
+            <Amt Ccy="EUR">80.00</Amt>
+          </TxDtls>
+          <TxDtls>
+            <Refs><EndToEndId>NOTPROVIDED</EndToEndId><MndtId>252-2</MndtId></Refs>
+            <Amt Ccy="EUR">80.00</Amt>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">960.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-06</Dt></BookgDt>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><AcctSvcrRef>UEB-1</AcctSvcrRef></Refs>
+            <RltdPties>
+              <Dbtr><Pty><Nm>Erika Mustermann</Nm></Pty></Dbtr>
+              <DbtrAcct><Id><IBAN>DE02120300000000202051</IBAN></Id></DbtrAcct>
+            </RltdPties>
+            <RmtInf><Ustrd>Gemueseanteil Bietnummer</Ustrd><Ustrd>123456789</Ustrd></RmtInf>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">50.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-07</Dt></BookgDt>
+        <AcctSvcrRef>UEB-2</AcctSvcrRef>
+        <AddtlNtryInf>Spende</AddtlNtryInf>
+        <NtryDtls>
+          <TxDtls>
+            <RltdPties>
+              <Dbtr><Nm>Max Mustermann</Nm></Dbtr>
+              <DbtrAcct><Id><IBAN>DE 0212 0300 0000 0020 2052</IBAN></Id></DbtrAcct>
+            </RltdPties>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">20.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-08</Dt></BookgDt>
+        <AcctSvcrRef>UEB-3</AcctSvcrRef>
+        <AddtlNtryInf>Unbekannte Gutschrift</AddtlNtryInf>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">12.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-09</Dt></BookgDt>
+        <AddtlNtryInf>Kontoführung</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestCamtZahlungen(t *testing.T) {
+	buchungen, err := readCamt(strings.NewReader(camt053))
+	if err != nil {
+		t.Fatalf("readCamt: %v", err)
+	}
+
+	glaeubiger := config.Glaeubiger{MandatsreferenzVorlage: "25{bietnummer}"}
+
+	m := model.New()
+	m.Bieter[1] = model.Bieter{ID: 1}
+	m.Bieter[2] = model.Bieter{ID: 2, Mandat: model.Mandat{Nummer: 2}}
+	m.Bieter[3] = model.Bieter{ID: 3, Stammdaten: model.Stammdaten{IBAN: "DE02 1203 0000 0000 2020 52"}}
+	m.Bieter[123456789] = model.Bieter{ID: 123456789}
+	m.Zahlungen[1] = model.Zahlung{ID: 1, BietID: 1, Betrag: 8000, Datum: time.Date(2026, time.May, 4, 0, 0, 0, 0, time.UTC), Referenz: "251-20260504", Zuordnung: model.ZuordnungMandat}
+
+	abgleich := camtZahlungenZuordnen(m, buchungen, glaeubiger)
+
+	if abgleich.Bekannt != 1 {
+		t.Errorf("got %d known payments, expected 1", abgleich.Bekannt)
+	}
+
+	if len(abgleich.Unbekannt) != 1 || abgleich.Unbekannt[0].Verwendungszweck != "Unbekannte Gutschrift" {
+		t.Errorf("got unknown transactions %v, expected the transaction of 20 €", abgleich.Unbekannt)
+	}
+
+	expect := []model.Zahlung{
+		{BietID: 2, Betrag: 8000, Datum: time.Date(2026, time.May, 4, 0, 0, 0, 0, time.UTC), Referenz: "SAMMLER-1", Zuordnung: model.ZuordnungMandat},
+		{BietID: 123456789, Betrag: 96000, Datum: time.Date(2026, time.May, 6, 0, 0, 0, 0, time.UTC), Referenz: "UEB-1", Verwendungszweck: "Gemueseanteil Bietnummer 123456789", Name: "Erika Mustermann", IBAN: "DE02120300000000202051", Zuordnung: model.ZuordnungBietnummer},
+		{BietID: 3, Betrag: 5000, Datum: time.Date(2026, time.May, 7, 0, 0, 0, 0, time.UTC), Referenz: "UEB-2", Name: "Max Mustermann", IBAN: "DE02120300000000202052", Zuordnung: model.ZuordnungIBAN},
+	}
+	if len(abgleich.Zahlungen) != len(expect) {
+		t.Fatalf("got payments %v, expected %v", abgleich.Zahlungen, expect)
+	}
+	for i, z := range abgleich.Zahlungen {
+		if z != expect[i] {
+			t.Errorf("got payment %v, expected %v", z, expect[i])
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package web
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+// datevSpalten are the columns of a DATEV Buchungsstapel up to the cost
+// centres. The later columns are not used by the export.
+var datevSpalten = []string{
+	"Umsatz (ohne Soll/Haben-Kz)",
+	"Soll/Haben-Kennzeichen",
+	"WKZ Umsatz",
+	"Kurs",
+	"Basis-Umsatz",
+	"WKZ Basis-Umsatz",
+	"Konto",
+	"Gegenkonto (ohne BU-Schlüssel)",
+	"BU-Schlüssel",
+	"Belegdatum",
+	"Belegfeld 1",
+	"Belegfeld 2",
+	"Skonto",
+	"Buchungstext",
+	"Postensperre",
+	"Diverse Adressnummer",
+	"Geschäftspartnerbank",
+	"Sachverhalt",
+	"Zinssperre",
+	"Beleglink",
+	"Beleginfo - Art 1",
+	"Beleginfo - Inhalt 1",
+	"Beleginfo - Art 2",
+	"Beleginfo - Inhalt 2",
+	"Beleginfo - Art 3",
+	"Beleginfo - Inhalt 3",
+	"Beleginfo - Art 4",
+	"Beleginfo - Inhalt 4",
+	"Beleginfo - Art 5",
+	"Beleginfo - Inhalt 5",
+	"Beleginfo - Art 6",
+	"Beleginfo - Inhalt 6",
+	"Beleginfo - Art 7",
+	"Beleginfo - Inhalt 7",
+	"Beleginfo - Art 8",
+	"Beleginfo - Inhalt 8",
+	"KOST1 - Kostenstelle",
+	"KOST2 - Kostenstelle",
+	"Kost-Menge",
+}
+
+// datevBuchung is one booking of a contribution.
+type datevBuchung struct {
+	Betrag       model.Gebot
+	Datum        time.Time
+	Belegfeld    string
+	Buchungstext string
+	Kostenstelle string
+}
+
+// datevBuchungen returns one booking for every direct debit of the saison.
+//
+// Monthly payers get a booking per month and yearly payers one booking for
+// the year. Debits, that collect a return again, are no new income and are
+// left out.
+func datevBuchungen(m model.Model, datev config.Datev) []datevBuchung {
+	var buchungen []datevBuchung
+	for bietID, termine := range m.Zahlungsplaene() {
+		bieter := m.Bieter[bietID]
+		for _, termin := range termine {
+			if termin.Nachholung {
+				continue
+			}
+
+			buchungen = append(buchungen, datevBuchung{
+				Betrag:       termin.Betrag,
+				Datum:        termin.Faelligkeit,
+				Belegfeld:    fmt.Sprintf("%d-%s", bietID, termin.Faelligkeit.Format("200601")),
+				Buchungstext: datev.Buchungstext(bietID, bieter.Name(), model.Monatsname(termin.Faelligkeit), m.Saison.Kuerzel()),
+				Kostenstelle: datev.KostenstelleFuer(bieter.VerteilstelleID),
+			})
+		}
+	}
+
+	slices.SortFunc(buchungen, func(a, b datevBuchung) int {
+		return cmp.Or(
+			a.Datum.Compare(b.Datum),
+			cmp.Compare(a.Belegfeld, b.Belegfeld),
+		)
+	})
+	return buchungen
+}
+
+// datevWirtschaftsjahr returns the first day of the fiscal year of a day.
+func datevWirtschaftsjahr(t time.Time, beginn int) time.Time {
+	jahr := t.Year()
+	if int(t.Month()) < beginn {
+		jahr--
+	}
+	return time.Date(jahr, time.Month(beginn), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// datevStapel splits the bookings by the year, the fiscal year starts in.
+func datevStapel(buchungen []datevBuchung, beginn int) map[int][]datevBuchung {
+	stapel := make(map[int][]datevBuchung)
+	for _, b := range buchungen {
+		jahr := datevWirtschaftsjahr(b.Datum, beginn).Year()
+		stapel[jahr] = append(stapel[jahr], b)
+	}
+	return stapel
+}
+
+// writeDatev writes the bookings of one fiscal year as DATEV Buchungsstapel.
+//
+// The file uses the EXTF format version 700 in the windows-1252 encoding
+// with CRLF line endings. The bookings have to be sorted by date.
+func writeDatev(w io.Writer, buchungen []datevBuchung, datev config.Datev, erzeugt time.Time) error {
+	if len(buchungen) == 0 {
+		return fmt.Errorf("no bookings")
+	}
+
+	if err := datev.Bereit(); err != nil {
+		return err
+	}
+
+	wirtschaftsjahr := datevWirtschaftsjahr(buchungen[0].Datum, datev.WirtschaftsjahrBeginn)
+
+	encoder := transform.NewWriter(w, encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder()))
+	buf := bufio.NewWriter(encoder)
+
+	von := buchungen[0].Datum
+	bis := buchungen[len(buchungen)-1].Datum
+
+	kopf := []string{
+		datevText("EXTF"),
+		"700",
+		"21",
+		datevText("Buchungsstapel"),
+		"13",
+		erzeugt.Format("20060102150405") + fmt.Sprintf("%03d", erzeugt.Nanosecond()/int(time.Millisecond)),
+		"",
+		datevText("RE"),
+		datevText("Bietrunde"),
+		datevText(""),
+		strconv.Itoa(datev.Beraternummer),
+		strconv.Itoa(datev.Mandantennummer),
+		wirtschaftsjahr.Format("20060102"),
+		strconv.Itoa(datev.Sachkontenlaenge),
+		von.Format("20060102"),
+		bis.Format("20060102"),
+		datevText(fmt.Sprintf("Beiträge %d", wirtschaftsjahr.Year())),
+		datevText(""),
+		"1",
+		"0",
+		"0",
+		datevText("EUR"),
+		"",
+		datevText(""),
+		"",
+		"",
+		datevText(datev.Kontenrahmen),
+		"",
+		"",
+		datevText(""),
+		datevText(""),
+	}
+	fmt.Fprintf(buf, "%s\r\n", strings.Join(kopf, ";"))
+	fmt.Fprintf(buf, "%s\r\n", strings.Join(datevSpalten, ";"))
+
+	for _, b := range buchungen {
+		zeile := make([]string, len(datevSpalten))
+		zeile[0] = fmt.Sprintf("%d,%02d", b.Betrag/100, b.Betrag%100)
+		zeile[1] = datevText("S")
+		zeile[2] = datevText("EUR")
+		zeile[6] = strconv.Itoa(datev.Konto)
+		zeile[7] = strconv.Itoa(datev.Gegenkonto)
+		zeile[9] = b.Datum.Format("0201")
+		zeile[10] = datevText(b.Belegfeld)
+		zeile[13] = datevText(datevKuerzen(b.Buchungstext, 60))
+		zeile[36] = datevText(b.Kostenstelle)
+		fmt.Fprintf(buf, "%s\r\n", strings.Join(zeile, ";"))
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("writing buchungsstapel: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("encoding buchungsstapel: %w", err)
+	}
+	return nil
+}
+
+// datevText quotes a text field.
+func datevText(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// datevKuerzen shortens a text to the maximum length of a DATEV field.
+func datevKuerzen(s string, laenge int) string {
+	if r := []rune(s); len(r) > laenge {
+		return string(r[:laenge])
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestDatev(t *testing.T) {
+	datev := config.Datev{
+		Beraternummer:         1001,
+		Mandantennummer:       42,
+		Sachkontenlaenge:      4,
+		Kontenrahmen:          "03",
+		WirtschaftsjahrBeginn: 1,
+		Konto:                 1200,
+		Gegenkonto:            8200,
+		Kostenstelle:          "100",
+		Kostenstellen:         map[string]string{"1": "110"},
+		BuchungstextVorlage:   "Beitrag {monat} {name}",
+	}
+
+	m := model.New()
+	m.Verteilstellen[1] = model.Verteilstelle{ID: 1, Name: "Hof"}
+	m.Bieter[1] = model.Bieter{ID: 1, Gebot: 8000, Stammdaten: model.Stammdaten{Vorname: "Jörg", Nachname: "Müller", VerteilstelleID: 1}}
+	m.Bieter[2] = model.Bieter{ID: 2, Gebot: 9050, Stammdaten: model.Stammdaten{Vorname: "Erika", Nachname: "Muster", Jaehrlich: true}}
+
+	buchungen := datevBuchungen(m, datev)
+	if len(buchungen) != 13 {
+		t.Fatalf("got %d bookings, expected 13", len(buchungen))
+	}
+
+	stapel := datevStapel(buchungen, datev.WirtschaftsjahrBeginn)
+	if len(stapel[2026]) != 10 || len(stapel[2027]) != 3 {
+		t.Fatalf("got %d bookings in 2026 and %d in 2027, expected 10 and 3", len(stapel[2026]), len(stapel[2027]))
+	}
+
+	var buf bytes.Buffer
+	erzeugt := time.Date(2026, time.October, 18, 12, 30, 0, 0, time.UTC)
+	if err := writeDatev(&buf, stapel[2026], datev, erzeugt); err != nil {
+		t.Fatalf("writeDatev: %v", err)
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decoding windows-1252: %v", err)
+	}
+
+	zeilen := strings.Split(string(decoded), "\r\n")
+	if len(zeilen) != 13 || zeilen[12] != "" {
+		t.Fatalf("got %d lines, expected header, columns, 10 bookings and a final line break", len(zeilen))
+	}
+
+	felder := strings.Split(zeilen[0], ";")
+	if felder[10] != "1001" || felder[11] != "42" {
+		t.Errorf("got beraternummer %s and mandantennummer %s, expected 1001 and 42", felder[10], felder[11])
+	}
+
+	kopf := `"EXTF";700;21;"Buchungsstapel";13;20261018123000000;;"RE";"Bietrunde";"";1001;42;20260101;4;20260402;20261201;"Beiträge 2026";"";1;0;0;"EUR";;"";;;"03";;;"";""`
+	if zeilen[0] != kopf {
+		t.Errorf("got header\n%s\nexpected\n%s", zeilen[0], kopf)
+	}
+
+	for _, expect := range []string{
+		`80,00;"S";"EUR";;;;1200;8200;;0204;"1-202604";;;"Beitrag April 2026 Jörg Müller";;;;;;;;;;;;;;;;;;;;;;;"110";;`,
+		`1086,00;"S";"EUR";;;;1200;8200;;0204;"2-202604";;;"Beitrag April 2026 Erika Muster";;;;;;;;;;;;;;;;;;;;;;;"100";;`,
+	} {
+		if !strings.Contains(string(decoded), expect+"\r\n") {
+			t.Errorf("booking %s not found in\n%s", expect, decoded)
+		}
+	}
+
+	ohneNummern := datev
+	ohneNummern.Beraternummer = 0
+	if err := writeDatev(&buf, stapel[2026], ohneNummern, erzeugt); err == nil {
+		t.Errorf("writeDatev without beraternummer: expected an error")
+	}
+}
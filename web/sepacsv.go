@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/ostcar/bietrunde/config"
 	"github.com/ostcar/bietrunde/model"
 )
 
@@ -11,29 +12,55 @@ const SEPACSVHeader = `Basislastschrift;;;;;;;;;;;;;;;;;;;;
 Fälligkeitstermin;Zahlungspflichtiger;Straße;Gebäude Nr.;Postleitzahl;Stadt;Länder-Kennzeichen;Land;IBAN Zahlungspflichtiger;BIC;Bei Kreditinstitut;Betrag;Verwendungszweck 1;Verwendungszweck 2;IBAN des Zahlungsempfängers;Zahlungsempfänger;Abweichender Zahlungsempfänger;Mandatsreferenz;Unterschrieben am;Ausführungsart;Gläubiger ID des Zahlungsempfängers
 `
 
-const SEPACSVLine = `%s;%s;;;;;;;%s;;;%s;Gemüseanteil 26.27 Baarfood;;DE24643901300278501001;Solidarische Landwirtschaft;;25%d;26.11.2025;einmalig;DE62ZZZ00001997635
+const SEPACSVLine = `%s;%s;%s;%s;%s;%s;%s;%s;%s;;;%s;%s;;%s;%s;;%s;%s;%s;%s
 `
 
-const abbuchung = "02.04.2026"
+// ausfuehrungsart are the names of the sequence types in the csv.
+var ausfuehrungsart = map[model.Sequenz]string{
+	model.SequenzErste:    "erstmalig",
+	model.SequenzFolge:    "wiederkehrend",
+	model.SequenzEinmalig: "einmalig",
+}
+
+// writeSEPACSVToZip writes the direct debits of the first month of the saison
+// for the bank portal. The yearly and the monthly payers get separate files.
+//
+// The debits have to be the same as in the xml file, so both files collect
+// from the same bieter.
+func writeSEPACSVToZip(w io.Writer, lastschriften []model.Lastschrift, bieter map[int]model.Bieter, saison model.Saison, glaeubiger config.Glaeubiger, jaehrlich bool) error {
+	abbuchung := saison.ErsteAbbuchung.Format("02.01.2006")
 
-func writeSEPACSVToZip(w io.Writer, bieter []model.Bieter, jaehrlich bool) error {
 	if _, err := w.Write([]byte(SEPACSVHeader)); err != nil {
 		return fmt.Errorf("write header: %w", err)
 	}
 
-	for _, b := range bieter {
-		if b.Gebot.Empty() {
-			continue
-		}
-
-		if jaehrlich && b.Jaehrlich {
-			fmt.Fprintf(w, SEPACSVLine, abbuchung, b.ShowKontoinhaber(), b.IBANTrimed(), b.Gebot.ForYear().NumberString(), b.ID)
+	for _, l := range lastschriften {
+		b := bieter[l.BietID]
+		if jaehrlich != b.Jaehrlich {
 			continue
 		}
 
-		if !jaehrlich && !b.Jaehrlich {
-			fmt.Fprintf(w, SEPACSVLine, abbuchung, b.ShowKontoinhaber(), b.IBANTrimed(), b.Gebot.NumberString(), b.ID)
-		}
+		fmt.Fprintf(
+			w,
+			SEPACSVLine,
+			abbuchung,
+			l.Kontoinhaber,
+			b.Strasse,
+			b.Hausnummer,
+			b.PLZ,
+			b.Ort,
+			b.Land,
+			model.Laendername(b.Land),
+			l.IBAN,
+			l.Betrag.NumberString(),
+			glaeubiger.Verwendungszweck(saison.Kuerzel()),
+			glaeubiger.IBAN,
+			glaeubiger.Name,
+			glaeubiger.Mandatsreferenz(l.BietID, l.Mandat),
+			l.MandatDatum.Format("02.01.2006"),
+			ausfuehrungsart[l.Sequenz],
+			glaeubiger.GlaeubigerID,
+		)
 	}
 
 	return nil
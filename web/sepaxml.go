@@ -0,0 +1,297 @@
+package web
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jbub/banking/iban"
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+)
+
+const (
+	painNamespace    = "urn:iso:std:iso:20022:tech:xsd:pain.008.001.02"
+	painNichtBekannt = "NOTPROVIDED"
+)
+
+// writePain008 writes the direct debits of an einzug as ISO 20022
+// pain.008.001.02 xml.
+//
+// The debits are grouped by the due date and the sequence type. The account
+// of the bieter is taken from the debit, so the file does not change, when
+// the bieter changes his account later. Einzuege, that were exported without
+// the account, use the current data of the bieter. Debits without a valid
+// IBAN are left out, since the bank would reject the whole file.
+func writePain008(w io.Writer, einzug model.Einzug, bieter map[int]model.Bieter, saison model.Saison, glaeubiger config.Glaeubiger) error {
+	msgID := "BIETRUNDE-" + einzug.Exportiert.Format("20060102150405")
+
+	type gruppe struct {
+		faelligkeit time.Time
+		sequenz     model.Sequenz
+	}
+	gruppen := make(map[gruppe][]painTransaktion)
+
+	var anzahl int
+	var summe model.Gebot
+	for _, l := range einzug.Lastschriften {
+		b := bieter[l.BietID]
+		if l.IBAN == "" {
+			l.Kontoinhaber = b.ShowKontoinhaber()
+			l.IBAN = b.IBANTrimed()
+			l.MandatDatum = mandatDatum(b, saison)
+		}
+
+		if _, err := iban.Parse(l.IBAN); err != nil {
+			continue
+		}
+
+		key := gruppe{einzug.Faelligkeit, l.Sequenz}
+		gruppen[key] = append(gruppen[key], painTransaktionVon(l, b, einzug.Faelligkeit, saison, glaeubiger))
+		anzahl++
+		summe += l.Betrag
+	}
+
+	keys := make([]gruppe, 0, len(gruppen))
+	for key := range gruppen {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b gruppe) int {
+		return cmp.Or(
+			a.faelligkeit.Compare(b.faelligkeit),
+			cmp.Compare(a.sequenz, b.sequenz),
+		)
+	})
+
+	doc := painDocument{
+		Xmlns: painNamespace,
+		GrpHdr: painGroupHeader{
+			MsgID:    msgID,
+			CreDtTm:  einzug.Exportiert.Format("2006-01-02T15:04:05"),
+			NbOfTxs:  anzahl,
+			CtrlSum:  painBetrag(summe),
+			InitgPty: painName{Nm: sepaText(glaeubiger.Name, 70)},
+		},
+	}
+
+	for i, key := range keys {
+		transaktionen := gruppen[key]
+		slices.SortFunc(transaktionen, func(a, b painTransaktion) int {
+			return cmp.Compare(a.PmtID.EndToEndID, b.PmtID.EndToEndID)
+		})
+
+		var gruppenSumme model.Gebot
+		for _, t := range transaktionen {
+			gruppenSumme += t.betrag
+		}
+
+		info := painPaymentInfo{
+			PmtInfID:     fmt.Sprintf("%s-%d", msgID, i+1),
+			PmtMtd:       "DD",
+			NbOfTxs:      len(transaktionen),
+			CtrlSum:      painBetrag(gruppenSumme),
+			ReqdColltnDt: key.faelligkeit.Format(time.DateOnly),
+			Cdtr:         painName{Nm: sepaText(glaeubiger.Name, 70)},
+			CdtrAcct:     painKonto{IBAN: glaeubiger.IBAN},
+			CdtrAgt:      painAgentNichtBekannt(),
+			ChrgBr:       "SLEV",
+			DrctDbtTxInf: transaktionen,
+		}
+		info.PmtTpInf.SvcLvl.Cd = "SEPA"
+		info.PmtTpInf.LclInstrm.Cd = "CORE"
+		info.PmtTpInf.SeqTp = string(key.sequenz)
+		info.CdtrSchmeID.ID.PrvtID.Othr.ID = glaeubiger.GlaeubigerID
+		info.CdtrSchmeID.ID.PrvtID.Othr.SchmeNm.Prtry = "SEPA"
+
+		doc.PmtInf = append(doc.PmtInf, info)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("write xml header: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("encode pain.008: %w", err)
+	}
+	return nil
+}
+
+func painTransaktionVon(l model.Lastschrift, bieter model.Bieter, faelligkeit time.Time, saison model.Saison, glaeubiger config.Glaeubiger) painTransaktion {
+	mandatsreferenz := glaeubiger.Mandatsreferenz(l.BietID, l.Mandat)
+
+	t := painTransaktion{
+		betrag:  l.Betrag,
+		DbtrAgt: painAgentNichtBekannt(),
+		Dbtr: painDebitor{
+			Nm: sepaText(l.Kontoinhaber, 70),
+		},
+		DbtrAcct: painKonto{IBAN: l.IBAN},
+	}
+	t.PmtID.EndToEndID = endToEndID(l, faelligkeit, glaeubiger)
+	t.InstdAmt.Ccy = "EUR"
+	t.InstdAmt.Value = painBetrag(l.Betrag)
+	t.DrctDbtTx.MndtRltdInf.MndtID = mandatsreferenz
+	t.DrctDbtTx.MndtRltdInf.DtOfSgntr = l.MandatDatum.Format(time.DateOnly)
+	verwendungszweck := glaeubiger.Verwendungszweck(saison.Kuerzel())
+	if l.Teilpartner != 0 {
+		verwendungszweck += ", halber Anteil"
+	}
+	t.RmtInf.Ustrd = sepaText(verwendungszweck, 140)
+
+	if bieter.Land != "" {
+		t.Dbtr.PstlAdr = &painAdresse{Ctry: bieter.Land}
+		for zeile := range strings.SplitSeq(bieter.Anschrift(), "\n") {
+			if zeile != "" && len(t.Dbtr.PstlAdr.AdrLine) < 2 && zeile != model.Laendername(bieter.Land) {
+				t.Dbtr.PstlAdr.AdrLine = append(t.Dbtr.PstlAdr.AdrLine, sepaText(zeile, 70))
+			}
+		}
+	}
+	return t
+}
+
+// endToEndID returns the id of a direct debit, that the bank reports back on
+// a return.
+//
+// A debit, that collects a return again, gets the id of the return as suffix,
+// so it differs from the regular debit of the same month.
+func endToEndID(l model.Lastschrift, faelligkeit time.Time, glaeubiger config.Glaeubiger) string {
+	id := glaeubiger.Mandatsreferenz(l.BietID, l.Mandat) + "-" + faelligkeit.Format("20060102")
+	if l.Ruecklastschrift != 0 {
+		id += fmt.Sprintf("-R%d", l.Ruecklastschrift)
+	}
+	return sepaText(id, 35)
+}
+
+// mandatDatum returns the signature date of the mandate of the bieter. For
+// bieter without a mandate, the date of the contracts of the saison is used.
+func mandatDatum(bieter model.Bieter, saison model.Saison) time.Time {
+	if bieter.Mandat.Leer() {
+		return saison.MandatDatum
+	}
+	return bieter.Mandat.Unterschrieben
+}
+
+// painBetrag formats an amount with a decimal point, for example "80.00".
+func painBetrag(g model.Gebot) string {
+	return fmt.Sprintf("%d.%02d", int(g)/100, int(g)%100)
+}
+
+// sepaText shortens a text to the maximum length of a SEPA field.
+func sepaText(text string, maxLength int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) > maxLength {
+		runes = runes[:maxLength]
+	}
+	return string(runes)
+}
+
+func painAgentNichtBekannt() painAgent {
+	var agent painAgent
+	agent.FinInstnID.Othr.ID = painNichtBekannt
+	return agent
+}
+
+type painDocument struct {
+	XMLName xml.Name          `xml:"Document"`
+	Xmlns   string            `xml:"xmlns,attr"`
+	GrpHdr  painGroupHeader   `xml:"CstmrDrctDbtInitn>GrpHdr"`
+	PmtInf  []painPaymentInfo `xml:"CstmrDrctDbtInitn>PmtInf"`
+}
+
+type painGroupHeader struct {
+	MsgID    string   `xml:"MsgId"`
+	CreDtTm  string   `xml:"CreDtTm"`
+	NbOfTxs  int      `xml:"NbOfTxs"`
+	CtrlSum  string   `xml:"CtrlSum"`
+	InitgPty painName `xml:"InitgPty"`
+}
+
+type painName struct {
+	Nm string `xml:"Nm"`
+}
+
+type painKonto struct {
+	IBAN string `xml:"Id>IBAN"`
+}
+
+type painAgent struct {
+	FinInstnID struct {
+		Othr struct {
+			ID string `xml:"Id"`
+		} `xml:"Othr"`
+	} `xml:"FinInstnId"`
+}
+
+type painPaymentInfo struct {
+	PmtInfID string `xml:"PmtInfId"`
+	PmtMtd   string `xml:"PmtMtd"`
+	NbOfTxs  int    `xml:"NbOfTxs"`
+	CtrlSum  string `xml:"CtrlSum"`
+	PmtTpInf struct {
+		SvcLvl struct {
+			Cd string `xml:"Cd"`
+		} `xml:"SvcLvl"`
+		LclInstrm struct {
+			Cd string `xml:"Cd"`
+		} `xml:"LclInstrm"`
+		SeqTp string `xml:"SeqTp"`
+	} `xml:"PmtTpInf"`
+	ReqdColltnDt string    `xml:"ReqdColltnDt"`
+	Cdtr         painName  `xml:"Cdtr"`
+	CdtrAcct     painKonto `xml:"CdtrAcct"`
+	CdtrAgt      painAgent `xml:"CdtrAgt"`
+	ChrgBr       string    `xml:"ChrgBr"`
+	CdtrSchmeID  struct {
+		ID struct {
+			PrvtID struct {
+				Othr struct {
+					ID      string `xml:"Id"`
+					SchmeNm struct {
+						Prtry string `xml:"Prtry"`
+					} `xml:"SchmeNm"`
+				} `xml:"Othr"`
+			} `xml:"PrvtId"`
+		} `xml:"Id"`
+	} `xml:"CdtrSchmeId"`
+	DrctDbtTxInf []painTransaktion `xml:"DrctDbtTxInf"`
+}
+
+type painTransaktion struct {
+	betrag model.Gebot
+
+	PmtID struct {
+		EndToEndID string `xml:"EndToEndId"`
+	} `xml:"PmtId"`
+	InstdAmt struct {
+		Ccy   string `xml:"Ccy,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"InstdAmt"`
+	DrctDbtTx struct {
+		MndtRltdInf struct {
+			MndtID    string `xml:"MndtId"`
+			DtOfSgntr string `xml:"DtOfSgntr"`
+		} `xml:"MndtRltdInf"`
+	} `xml:"DrctDbtTx"`
+	DbtrAgt  painAgent   `xml:"DbtrAgt"`
+	Dbtr     painDebitor `xml:"Dbtr"`
+	DbtrAcct painKonto   `xml:"DbtrAcct"`
+	RmtInf   struct {
+		Ustrd string `xml:"Ustrd"`
+	} `xml:"RmtInf"`
+}
+
+type painDebitor struct {
+	Nm      string       `xml:"Nm"`
+	PstlAdr *painAdresse `xml:"PstlAdr,omitempty"`
+}
+
+type painAdresse struct {
+	Ctry    string   `xml:"Ctry"`
+	AdrLine []string `xml:"AdrLine"`
+}
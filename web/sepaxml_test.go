@@ -0,0 +1,152 @@
+package web
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/model"
+)
+
+func TestPain008(t *testing.T) {
+	saison := model.Saison{
+		Start:          time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Ende:           time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC),
+		ErsteAbbuchung: time.Date(2026, time.April, 2, 0, 0, 0, 0, time.UTC),
+		MandatDatum:    time.Date(2025, time.November, 26, 0, 0, 0, 0, time.UTC),
+	}
+
+	glaeubiger := config.Glaeubiger{
+		Name:                    "Solidarische Landwirtschaft",
+		IBAN:                    "DE24643901300278501001",
+		GlaeubigerID:            "DE62ZZZ00001997635",
+		VerwendungszweckVorlage: "Gemüseanteil {saison}",
+		MandatsreferenzVorlage:  "25{bietnummer}",
+	}
+
+	bieter := map[int]model.Bieter{
+		1: {ID: 1, Gebot: 8000, Stammdaten: model.Stammdaten{Vorname: "Max", Nachname: "Müller", IBAN: "DE02 1203 0000 0000 2020 51", Strasse: "Weg", Hausnummer: "1", PLZ: "78048", Ort: "Villingen", Land: "DE"}},
+		2: {ID: 2, Gebot: 9050, Stammdaten: model.Stammdaten{Vorname: "Erika", Nachname: "Muster", IBAN: "DE02100100100006820101", Jaehrlich: true}},
+		3: {ID: 3, Gebot: 7000, Stammdaten: model.Stammdaten{Vorname: "Ohne", Nachname: "IBAN"}},
+	}
+
+	// The debit of bieter 2 was exported with the account, that the bieter had
+	// at the time. The others are from an einzug, that was exported before
+	// the account was saved, and use the current data of the bieter.
+	einzug := model.Einzug{
+		Monat:       saison.Start,
+		Faelligkeit: saison.ErsteAbbuchung,
+		Exportiert:  time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC),
+		Lastschriften: []model.Lastschrift{
+			{BietID: 1, Betrag: 8000, Sequenz: model.SequenzErste},
+			{
+				BietID:       2,
+				Betrag:       108600,
+				Sequenz:      model.SequenzEinmalig,
+				Kontoinhaber: "Erika Muster",
+				IBAN:         "DE89370400440532013000",
+				MandatDatum:  time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC),
+			},
+			{BietID: 3, Betrag: 7000, Sequenz: model.SequenzErste},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writePain008(&buf, einzug, bieter, saison, glaeubiger); err != nil {
+		t.Fatalf("writePain008: %v", err)
+	}
+
+	xml := buf.String()
+	for _, expect := range []string{
+		"<NbOfTxs>2</NbOfTxs>",
+		"<CtrlSum>1166.00</CtrlSum>",
+		"<SeqTp>FRST</SeqTp>",
+		"<SeqTp>OOFF</SeqTp>",
+		"<MndtId>251</MndtId>",
+		"<DtOfSgntr>2025-11-26</DtOfSgntr>",
+		"<IBAN>DE02120300000000202051</IBAN>",
+		"<IBAN>DE89370400440532013000</IBAN>",
+		"<DtOfSgntr>2026-01-15</DtOfSgntr>",
+		"<ReqdColltnDt>2026-04-02</ReqdColltnDt>",
+	} {
+		if !strings.Contains(xml, expect) {
+			t.Errorf("xml does not contain %s:\n%s", expect, xml)
+		}
+	}
+
+	if strings.Contains(xml, "DE02100100100006820101") {
+		t.Errorf("xml contains the current iban of bieter 2, expected the exported one")
+	}
+
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint is not installed")
+	}
+
+	file := filepath.Join(t.TempDir(), "pain.xml")
+	if err := os.WriteFile(file, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write xml: %v", err)
+	}
+
+	out, err := exec.Command(xmllint, "--noout", "--schema", "testdata/pain.008.001.02.xsd", file).CombinedOutput()
+	if err != nil {
+		t.Errorf("xml is not valid: %v\n%s", err, out)
+	}
+}
+
+func TestSEPACSV(t *testing.T) {
+	glaeubiger := config.Glaeubiger{
+		Name:                    "Solidarische Landwirtschaft",
+		IBAN:                    "DE24643901300278501001",
+		GlaeubigerID:            "DE62ZZZ00001997635",
+		VerwendungszweckVorlage: "Gemüseanteil {saison}",
+		MandatsreferenzVorlage:  "25{bietnummer}",
+	}
+
+	m := model.New()
+	m.Bieter[1] = model.Bieter{ID: 1, Gebot: 8000, Stammdaten: model.Stammdaten{Nachname: "Monatlich", IBAN: "DE02120300000000202051"}}
+	m.Bieter[2] = model.Bieter{ID: 2, Gebot: 9050, Stammdaten: model.Stammdaten{Nachname: "Jaehrlich", IBAN: "DE02100100100006820101", Jaehrlich: true}}
+	m.Bieter[3] = model.Bieter{ID: 3, Gebot: 7000, Warteliste: true, Stammdaten: model.Stammdaten{Nachname: "Warteliste", IBAN: "DE89370400440532013000"}}
+	m.Bieter[4] = model.Bieter{ID: 4, Gebot: 7000, Stammdaten: model.Stammdaten{Nachname: "Ohne IBAN"}}
+
+	lastschriften, _ := m.EinzugLastschriften(m.Saison.Start)
+
+	var monatlich, jaehrlich bytes.Buffer
+	if err := writeSEPACSVToZip(&monatlich, lastschriften, m.Bieter, m.Saison, glaeubiger, false); err != nil {
+		t.Fatalf("writeSEPACSVToZip monatlich: %v", err)
+	}
+	if err := writeSEPACSVToZip(&jaehrlich, lastschriften, m.Bieter, m.Saison, glaeubiger, true); err != nil {
+		t.Fatalf("writeSEPACSVToZip jaehrlich: %v", err)
+	}
+
+	csv := monatlich.String() + jaehrlich.String()
+	for _, name := range []string{"Warteliste", "Ohne IBAN"} {
+		if strings.Contains(csv, name) {
+			t.Errorf("csv contains the bieter %q:\n%s", name, csv)
+		}
+	}
+
+	zeilen := strings.Count(monatlich.String(), "\n") - 2
+	if zeilen != 1 || !strings.Contains(monatlich.String(), ";80;") {
+		t.Errorf("got monthly csv\n%s\nexpected one debit of 80", monatlich.String())
+	}
+
+	if !strings.Contains(jaehrlich.String(), ";1086;") {
+		t.Errorf("got yearly csv\n%s\nexpected the yearly amount", jaehrlich.String())
+	}
+
+	var xml bytes.Buffer
+	einzug := model.Einzug{Monat: m.Saison.Start, Faelligkeit: m.Saison.ErsteAbbuchung, Lastschriften: lastschriften}
+	if err := writePain008(&xml, einzug, m.Bieter, m.Saison, glaeubiger); err != nil {
+		t.Fatalf("writePain008: %v", err)
+	}
+
+	if !strings.Contains(xml.String(), "<NbOfTxs>2</NbOfTxs>") {
+		t.Errorf("the xml does not contain the same 2 debits as the csv files")
+	}
+}
@@ -2,23 +2,26 @@ package web
 
 import (
 	"archive/zip"
+	"bytes"
 	"cmp"
 	"context"
 	"embed"
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"io"
 	"io/fs"
 	"log"
-	"maps"
 	"net"
 	"net/http"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/ostcar/bietrunde/config"
+	"github.com/ostcar/bietrunde/mail"
 	"github.com/ostcar/bietrunde/model"
 	"github.com/ostcar/bietrunde/pdf"
 	"github.com/ostcar/bietrunde/user"
@@ -69,12 +72,14 @@ type server struct {
 	http.Handler
 	cfg   config.Config
 	model *sticky.Sticky[model.Model]
+	mail  *mail.Sender
 }
 
 func newServer(cfg config.Config, s *sticky.Sticky[model.Model]) server {
 	srv := server{
 		cfg:   cfg,
 		model: s,
+		mail:  mail.New(cfg),
 	}
 	srv.registerHandlers()
 
@@ -90,6 +95,8 @@ func (s *server) registerHandlers() {
 	router.Handle("/", handleError(s.handleHome))
 	router.Handle("/edit", handleError(s.handleEdit))
 	router.Handle("/vertrag", handleError(s.handleVertrag))
+	router.Handle("/vorabankuendigung", handleError(s.handleVorabankuendigung))
+	router.Handle("/teilpartner", handleError(s.handleTeilpartner))
 	router.Handle("/sse", handleError(s.handleSSE))
 
 	router.Handle("/admin", handleError(s.adminPage(s.handleAdmin)))
@@ -101,11 +108,36 @@ func (s *server) registerHandlers() {
 	router.Handle("/admin/can_self_edit/{id:[0-9]+}", handleError(s.adminPage(s.handleAdminSetCanSelfEdit)))
 	router.Handle("/admin/can_not_self_edit/{id:[0-9]+}", handleError(s.adminPage(s.handleAdminSetCanNotSelfEdit)))
 	router.Handle("/admin/state", handleError(s.adminPage(s.handleAdminState)))
-	router.Handle("/admin/reset-gebot", handleError(s.adminPage(s.handleAdminResetGebot)))
+	router.Handle("/admin/neue-runde", handleError(s.adminPage(s.handleAdminNeueRunde)))
+	router.Handle("/admin/zielbetrag", handleError(s.adminPage(s.handleAdminZielbetrag)))
+	router.Handle("/admin/richtwert", handleError(s.adminPage(s.handleAdminRichtwert)))
+	router.Handle("/admin/gebotsregeln", handleError(s.adminPage(s.handleAdminGebotsregeln)))
+	router.Handle("/admin/saison", handleError(s.adminPage(s.handleAdminSaison)))
+	router.Handle("/admin/saison/neu", handleError(s.adminPage(s.handleSaisonNeu)))
+	router.Handle("/admin/archiv", handleError(s.adminPage(s.handleArchiv)))
+	router.Handle("/admin/archiv/{nr:[0-9]+}", handleError(s.adminPage(s.handleArchivDetail)))
 	router.Handle("/admin/zip", handleError(s.adminPage(s.handleAdminZIP)))
+	router.Handle("/admin/datev", handleError(s.adminPage(s.handleAdminDatev)))
+	router.Handle("/admin/bieter/export", handleError(s.adminPage(s.handleAdminBieterExport)))
+	router.Handle("/admin/import", handleError(s.adminPage(s.handleAdminImport)))
+	router.Handle("/admin/einzug", handleError(s.adminPage(s.handleEinzug)))
+	router.Handle("/admin/einzug/{monat:[0-9]{4}-[0-9]{2}}", handleError(s.adminPage(s.handleEinzugDownload)))
+	router.Handle("/admin/vorabankuendigung", handleError(s.adminPage(s.handleAdminVorabankuendigung)))
+	router.Handle("/admin/vorabankuendigung/mail", handleError(s.adminPage(s.handleAdminVorabankuendigungMail)))
+	router.Handle("/admin/ruecklastschriften", handleError(s.adminPage(s.handleRuecklastschriften)))
+	router.Handle("/admin/abgleich", handleError(s.adminPage(s.handleAbgleich)))
+	router.Handle("/admin/ruecklastschriften/{id:[0-9]+}/nachholen", handleError(s.adminPage(s.handleRuecklastschriftNachholen)))
 	router.Handle("/admin/verteilstellen", handleError(s.adminPage(s.handleVerteilstellen)))
+	router.Handle("/admin/verteilstellen/new", handleError(s.adminPage(s.handleVerteilstelleNew)))
+	router.Handle("/admin/verteilstellen/{id:[0-9]+}/edit", handleError(s.adminPage(s.handleVerteilstelleEdit)))
+	router.Handle("/admin/verteilstellen/{id:[0-9]+}/aktiv", handleError(s.adminPage(s.handleVerteilstelleAktiv)))
+	router.Handle("/admin/warteliste", handleError(s.adminPage(s.handleWarteliste)))
+	router.Handle("/admin/warteliste/{id:[0-9]+}", handleError(s.adminPage(s.handleWartelisteZuweisen)))
+	router.Handle("/admin/teilpartner", handleError(s.adminPage(s.handleTeilpartnersuche)))
 	router.Handle("/admin/sse", handleError(s.adminPage(s.handleAdminSSE)))
 
+	s.registerAPI(router)
+
 	s.Handler = loggingMiddleware(router)
 }
 
@@ -125,7 +157,7 @@ func (s server) handleHome(w http.ResponseWriter, r *http.Request) error {
 		u.BieterID = id
 		_, ok := m.Bieter[u.BieterID]
 		if u.IsAnonymous() || !ok {
-			return s.showLoginPage(r.Context(), w, state, "", "")
+			return s.showLoginPage(r.Context(), w, state, m.Saison, "", "")
 		}
 
 		u.SetCookie(w, []byte(s.cfg.Secret))
@@ -141,24 +173,40 @@ func (s server) handleHome(w http.ResponseWriter, r *http.Request) error {
 		return s.handleLoginPage(w, r)
 	}
 
-	state := m.State
+	data := bieterData(m, bieter)
 	done()
-	return s.handleBieterDetail(w, r, state, bieter)
+	return s.handleBieterDetail(w, r, data)
+}
+
+func bieterData(m model.Model, bieter model.Bieter) template.BieterData {
+	teilpartner, _ := m.Teilpartner(bieter)
+	return template.BieterData{
+		State:         m.State,
+		Bieter:        bieter,
+		Verteilstelle: m.Verteilstelle(bieter),
+		Runden:        m.BisherigeRunden(),
+		Richtwert:     m.Richtwert.Fuer(bieter.GanzOderHalb),
+		Saison:        m.Saison,
+		Teilpartner:   teilpartner,
+		Angefragt:     m.Bieter[bieter.TeilpartnerAnfrage],
+		Anfragen:      m.TeilpartnerAnfragen(bieter.ID),
+	}
 }
 
 func (s server) handleLoginPage(w http.ResponseWriter, r *http.Request) error {
 	m, done := s.model.ForReading()
 	state := m.State
+	saison := m.Saison
 	done()
 
 	switch r.Method {
 	case http.MethodGet:
 
-		return s.showLoginPage(r.Context(), w, state, "", "")
+		return s.showLoginPage(r.Context(), w, state, saison, "", "")
 
 	case http.MethodPost:
 		if err := r.ParseForm(); err != nil {
-			return s.showLoginPage(r.Context(), w, state, "Formular kann nicht gelesen werden. Bitte versuche es erneut.", "Formular kann nicht gelesen werden. Bitte versuche es erneut.")
+			return s.showLoginPage(r.Context(), w, state, saison, "Formular kann nicht gelesen werden. Bitte versuche es erneut.", "Formular kann nicht gelesen werden. Bitte versuche es erneut.")
 		}
 
 		switch r.Form.Get("form") {
@@ -169,7 +217,7 @@ func (s server) handleLoginPage(w http.ResponseWriter, r *http.Request) error {
 			return s.handleRegisterPost(w, r)
 
 		default:
-			return s.showLoginPage(r.Context(), w, state, "Formular kann nicht gelesen werden. Bitte versuche es erneut.", "Formular kann nicht gelesen werden. Bitte versuche es erneut.")
+			return s.showLoginPage(r.Context(), w, state, saison, "Formular kann nicht gelesen werden. Bitte versuche es erneut.", "Formular kann nicht gelesen werden. Bitte versuche es erneut.")
 		}
 
 	default:
@@ -178,8 +226,8 @@ func (s server) handleLoginPage(w http.ResponseWriter, r *http.Request) error {
 	}
 }
 
-func (s server) showLoginPage(ctx context.Context, w http.ResponseWriter, state model.ServiceState, loginFormError, registerFormError string) error {
-	return template.LoginPage(state, "", loginFormError, registerFormError).Render(ctx, w)
+func (s server) showLoginPage(ctx context.Context, w http.ResponseWriter, state model.ServiceState, saison model.Saison, loginFormError, registerFormError string) error {
+	return template.LoginPage(state, saison, "", loginFormError, registerFormError).Render(ctx, w)
 }
 
 func (s server) handleLoginPost(w http.ResponseWriter, r *http.Request) error {
@@ -190,7 +238,7 @@ func (s server) handleLoginPost(w http.ResponseWriter, r *http.Request) error {
 	_, ok := m.Bieter[bieterID]
 	state := m.State
 	if !ok {
-		return template.LoginPage(state, r.Form.Get("bietnumber"), "Bietnummer nicht bekannt. ", "").Render(r.Context(), w)
+		return template.LoginPage(state, m.Saison, r.Form.Get("bietnumber"), "Bietnummer nicht bekannt. ", "").Render(r.Context(), w)
 	}
 
 	user := user.FromID(bieterID)
@@ -205,12 +253,12 @@ func (s server) handleRegisterPost(w http.ResponseWriter, r *http.Request) error
 
 	state := m.State
 	if state != model.StateRegistration {
-		return template.LoginPage(m.State, "", "", "Registrierung nicht möglich").Render(r.Context(), w)
+		return template.LoginPage(m.State, m.Saison, "", "", "Registrierung nicht möglich").Render(r.Context(), w)
 	}
 
 	bieterID, event := m.BieterCreate()
 	if err := write(event); err != nil {
-		return template.LoginPage(m.State, "", "", userError(err)).Render(r.Context(), w)
+		return template.LoginPage(m.State, m.Saison, "", "", userError(err)).Render(r.Context(), w)
 	}
 
 	user := user.FromID(bieterID)
@@ -219,39 +267,48 @@ func (s server) handleRegisterPost(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
-func (s server) handleBieterDetail(w http.ResponseWriter, r *http.Request, state model.ServiceState, bieter model.Bieter) error {
+func (s server) handleBieterDetail(w http.ResponseWriter, r *http.Request, data template.BieterData) error {
+	state := data.State
+	bieter := data.Bieter
 	invalidFields := bieter.InvalidFields()
 	switch r.Method {
 	case http.MethodGet:
-		return template.Bieter(state, bieter, "", invalidFields).Render(r.Context(), w)
+		return template.Bieter(data, "", invalidFields).Render(r.Context(), w)
 
 	case http.MethodPost:
 		if err := r.ParseForm(); err != nil {
-			return template.Bieter(state, bieter, "Formular kann nicht gelesen werden. Bitte versuche es erneut.", invalidFields).Render(r.Context(), w)
+			return template.Bieter(data, "Formular kann nicht gelesen werden. Bitte versuche es erneut.", invalidFields).Render(r.Context(), w)
 		}
 
 		if r.Form.Get("form") != "gebot" {
-			return template.Bieter(state, bieter, "", invalidFields).Render(r.Context(), w)
+			return template.Bieter(data, "", invalidFields).Render(r.Context(), w)
 		}
 
 		if state != model.StateOffer {
-			return template.Bieter(state, bieter, "Aktuell kann kein Gebot abgegeben werden.", invalidFields).Render(r.Context(), w)
+			return template.Bieter(data, "Aktuell kann kein Gebot abgegeben werden.", invalidFields).Render(r.Context(), w)
 		}
 
 		if !bieter.Anwesend {
-			return template.Bieter(state, bieter, "Du musst anwesend sein um ein Gebot abzugeben.", invalidFields).Render(r.Context(), w)
+			return template.Bieter(data, "Du musst anwesend sein um ein Gebot abzugeben.", invalidFields).Render(r.Context(), w)
 		}
 
 		gebot, err := model.GebotFromString(r.Form.Get("gebot"))
 		if err != nil {
-			return template.Bieter(state, bieter, "Dein Gebot muss eine Zahl sein.", invalidFields).Render(r.Context(), w)
+			return template.Bieter(data, "Dein Gebot muss eine Zahl sein.", invalidFields).Render(r.Context(), w)
 		}
 
 		m, write, done := s.model.ForWriting()
 		defer done()
 
-		if err := write(m.SetGebot(bieter.ID, gebot)); err != nil {
-			return template.Bieter(state, bieter, userError(err), invalidFields).Render(r.Context(), w)
+		bestaetigt := r.Form.Has("bestaetigt")
+		if warnung := m.GebotWarnung(bieter.ID, gebot); warnung != "" && !bestaetigt {
+			data.Warnung = warnung
+			data.NeuesGebot = gebot
+			return template.Bieter(data, "", invalidFields).Render(r.Context(), w)
+		}
+
+		if err := write(m.SetGebot(bieter.ID, gebot, bestaetigt)); err != nil {
+			return template.Bieter(data, userError(err), invalidFields).Render(r.Context(), w)
 		}
 
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -283,7 +340,7 @@ func (s server) handleEdit(w http.ResponseWriter, r *http.Request) error {
 		bieter, ok := m.Bieter[user.BieterID]
 
 		if user.IsAnonymous() || !ok {
-			return template.LoginPage(m.State, "", "", "").Render(r.Context(), w)
+			return template.LoginPage(m.State, m.Saison, "", "", "").Render(r.Context(), w)
 		}
 
 		if !canEdit(m.State, bieter) {
@@ -291,7 +348,7 @@ func (s server) handleEdit(w http.ResponseWriter, r *http.Request) error {
 			return nil
 		}
 
-		return template.BieterEdit(bieter, bieter.InvalidFields()).Render(r.Context(), w)
+		return template.BieterEdit(bieter, m.VerteilstellenListe(), bieter.InvalidFields()).Render(r.Context(), w)
 
 	case http.MethodPost:
 		m, write, done := s.model.ForWriting()
@@ -312,13 +369,13 @@ func (s server) handleEdit(w http.ResponseWriter, r *http.Request) error {
 		if errMsg != "" {
 			invalidFields := bieter.InvalidFields()
 			invalidFields["form"] = errMsg
-			return template.BieterEdit(bieter, invalidFields).Render(r.Context(), w)
+			return template.BieterEdit(bieter, m.VerteilstellenListe(), invalidFields).Render(r.Context(), w)
 		}
 
-		if err := write(m.BieterUpdate(bieter)); err != nil {
+		if err := write(m.BieterUpdate(bieter.ID, bieter.Version, bieter.Stammdaten)); err != nil {
 			invalidFields := bieter.InvalidFields()
 			invalidFields["form"] = userError(err)
-			return template.BieterEdit(bieter, invalidFields).Render(r.Context(), w)
+			return template.BieterEdit(bieter, m.VerteilstellenListe(), invalidFields).Render(r.Context(), w)
 		}
 
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -330,6 +387,69 @@ func (s server) handleEdit(w http.ResponseWriter, r *http.Request) error {
 	}
 }
 
+func (s server) handleTeilpartner(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	user, err := user.FromRequest(r, []byte(s.cfg.Secret))
+	if err != nil || user.BieterID == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	bieter, ok := m.Bieter[user.BieterID]
+	if !ok {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}
+
+	showErr := func(errMsg string) error {
+		invalidFields := bieter.InvalidFields()
+		invalidFields["teilpartner-link"] = errMsg
+		return template.Bieter(bieterData(m, bieter), "", invalidFields).Render(r.Context(), w)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return showErr("Formular kann nicht gelesen werden. Bitte versuche es erneut.")
+	}
+
+	partnerID, _ := strconv.Atoi(r.Form.Get("partner"))
+
+	var event model.Event
+	switch r.Form.Get("aktion") {
+	case "anfrage":
+		partner, ok := m.BieterFuerEinladung(r.Form.Get("partner"))
+		if !ok {
+			return showErr("Bietnummer oder Einladungscode ist nicht bekannt.")
+		}
+		event = m.TeilpartnerAnfrage(bieter.ID, partner.ID)
+
+	case "bestaetigen":
+		event = m.TeilpartnerBestaetigen(bieter.ID, partnerID)
+
+	case "loesen":
+		event = m.TeilpartnerLoesen(bieter.ID, partnerID)
+
+	case "einladungscode":
+		event = m.EinladungscodeErzeugen(bieter.ID)
+
+	default:
+		return showErr("Formular kann nicht gelesen werden. Bitte versuche es erneut.")
+	}
+
+	if err := write(event); err != nil {
+		return showErr(userError(err))
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
 func (s server) handleVertrag(w http.ResponseWriter, r *http.Request) error {
 	user, err := user.FromRequest(r, []byte(s.cfg.Secret))
 	if err != nil || user.BieterID == 0 {
@@ -347,7 +467,7 @@ func (s server) handleVertrag(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
-	vertrag, err := pdf.Bietervertrag(s.cfg.BaseURL, bieter)
+	vertrag, err := pdf.Bietervertrag(s.cfg.BaseURL, bieter, m.Verteilstelle(bieter), m.Saison, s.cfg.Glaeubiger)
 	if err != nil {
 		return err
 	}
@@ -356,6 +476,39 @@ func (s server) handleVertrag(w http.ResponseWriter, r *http.Request) error {
 	return err
 }
 
+func (s server) handleVorabankuendigung(w http.ResponseWriter, r *http.Request) error {
+	user, err := user.FromRequest(r, []byte(s.cfg.Secret))
+	if err != nil || user.BieterID == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}
+
+	m, done := s.model.ForReading()
+	defer done()
+
+	bieter, ok := m.Bieter[user.BieterID]
+
+	if user.IsAnonymous() || !ok {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}
+
+	termine := m.Zahlungsplaene()[bieter.ID]
+	if m.State != model.StateFinish || len(termine) == 0 {
+		http.Error(w, "Für dich sind keine Abbuchungen geplant", http.StatusNotFound)
+		return nil
+	}
+
+	dokument, err := pdf.Vorabankuendigung(bieter, termine, m.Saison, s.cfg.Glaeubiger)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Add("Content-Type", "application/pdf")
+	_, err = w.Write(dokument)
+	return err
+}
+
 func (s server) handleSSE(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Add("Content-Type", "text/event-stream")
 	w.Header().Add("Content-Disposition", "inline")
@@ -375,7 +528,7 @@ func (s server) handleSSE(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	s.model.Listen(r.Context())(func(events []string) bool {
-		if slices.Contains(events, "set-state") {
+		if slices.Contains(events, "set-state") || slices.Contains(events, "gebot-reset") || slices.Contains(events, "richtwert") {
 			return s.sendGebotShow(r.Context(), w, u.BieterID)
 		}
 		return true
@@ -386,14 +539,13 @@ func (s server) handleSSE(w http.ResponseWriter, r *http.Request) error {
 func (s server) sendGebotShow(ctx context.Context, w http.ResponseWriter, bietID int) bool {
 	m, done := s.model.ForReading()
 	defer done()
-	state := m.State
 	bieter, ok := m.Bieter[bietID]
 	if !ok {
 		return false
 	}
 
 	w.Write([]byte("data: "))
-	if err := template.GebotShow(state, bieter, "").Render(ctx, w); err != nil {
+	if err := template.GebotShow(bieterData(m, bieter), "").Render(ctx, w); err != nil {
 		return false
 	}
 	w.Write([]byte("\n\n"))
@@ -409,15 +561,26 @@ func parseBieterEdit(r *http.Request, bieter model.Bieter) (model.Bieter, string
 	bieter.Vorname = strings.TrimSpace(r.Form.Get("vorname"))
 	bieter.Nachname = strings.TrimSpace(r.Form.Get("nachname"))
 	bieter.Mail = strings.TrimSpace(r.Form.Get("mail"))
-	bieter.Adresse = strings.TrimSpace(r.Form.Get("adresse"))
+	bieter.Strasse = strings.TrimSpace(r.Form.Get("strasse"))
+	bieter.Hausnummer = strings.TrimSpace(r.Form.Get("hausnummer"))
+	bieter.PLZ = strings.TrimSpace(r.Form.Get("plz"))
+	bieter.Ort = strings.TrimSpace(r.Form.Get("ort"))
+	bieter.Land = strings.ToUpper(strings.TrimSpace(r.Form.Get("land")))
 	bieter.Telefon = strings.TrimSpace(r.Form.Get("telefon"))
 	bieter.Mitglied = r.Form.Has("mitglied")
-	bieter.Verteilstelle = model.VerteilstelleFromAttr(strings.TrimSpace(r.Form.Get("verteilstelle")))
+	bieter.VerteilstelleID, _ = strconv.Atoi(r.Form.Get("verteilstelle"))
 	bieter.GanzOderHalb = model.GanzOderHalbFromAttr(strings.TrimSpace(r.Form.Get("ganzOderHalb")))
 	bieter.Teilpartner = strings.TrimSpace(r.Form.Get("teilpartner"))
+	bieter.KontaktTeilen = r.Form.Has("kontakt_teilen")
 	bieter.IBAN = strings.TrimSpace(r.Form.Get("iban"))
 	bieter.Kontoinhaber = strings.TrimSpace(r.Form.Get("kontoinhaber"))
 	bieter.Jaehrlich = strings.TrimSpace(r.Form.Get("abbuchung")) == "jaehrlich"
+
+	version, err := strconv.Atoi(r.Form.Get("version"))
+	if err != nil {
+		return bieter, "Formular kann nicht gelesen werden. Versuche es erneut"
+	}
+	bieter.Version = version
 	return bieter, ""
 }
 
@@ -479,7 +642,7 @@ func (s server) handleAdmin(w http.ResponseWriter, r *http.Request) error {
 	sort := getSort(r)
 
 	if r.Header.Get("HX-Request") == "true" {
-		eTag := bieterETagValue(bieter, sort)
+		eTag := bieterETagValue(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), sort)
 		w.Header().Add("ETag", eTag)
 		w.Header().Add("Vary", "HX-Request, X-Sort")
 
@@ -488,14 +651,17 @@ func (s server) handleAdmin(w http.ResponseWriter, r *http.Request) error {
 			return nil
 		}
 
-		return template.AdminUserTable(bieter, sort).Render(r.Context(), w)
+		return template.AdminUserTable(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), sort).Render(r.Context(), w)
 	}
-	return template.Admin(m.State, bieter, sort).Render(r.Context(), w)
+	return template.Admin(m.State, bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), adminDeckung(m), m.Gebotsregeln, m.Saison, sort).Render(r.Context(), w)
 }
 
-func bieterETagValue(bieter []model.Bieter, sort string) string {
+func bieterETagValue(bieter []model.Bieter, verteilstellen []model.Verteilstelle, runden []model.Runde, ruecklastschriften map[int]bool, sort string) string {
 	h := crc32.NewIEEE()
 	fmt.Fprint(h, bieter)
+	fmt.Fprint(h, verteilstellen)
+	fmt.Fprint(h, runden)
+	fmt.Fprint(h, ruecklastschriften)
 	fmt.Fprint(h, sort)
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
@@ -534,11 +700,11 @@ func (s server) handleAdminNew(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	bieter := m.Bieter[bieterID]
-	if err := template.AdminUserTable(adminBieterList(m), getSort(r)).Render(r.Context(), w); err != nil {
+	if err := template.AdminUserTable(adminBieterList(m), m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w); err != nil {
 		return err
 	}
 
-	if err := template.AdminBieterEdit(bieter, bieter.InvalidFields()).Render(r.Context(), w); err != nil {
+	if err := template.AdminBieterEdit(bieter, m.VerteilstellenListe(), s.cfg.Glaeubiger.Mandatsreferenz(bieter.ID, bieter.Mandat.Nummer), bieter.InvalidFields()).Render(r.Context(), w); err != nil {
 		return err
 	}
 
@@ -558,7 +724,7 @@ func (s server) handleAdminEdit(w http.ResponseWriter, r *http.Request) error {
 			return fmt.Errorf("Admin get: edit an non existing bieter")
 		}
 
-		return template.AdminBieterEdit(bieter, bieter.InvalidFields()).Render(r.Context(), w)
+		return template.AdminBieterEdit(bieter, m.VerteilstellenListe(), s.cfg.Glaeubiger.Mandatsreferenz(bieter.ID, bieter.Mandat.Nummer), bieter.InvalidFields()).Render(r.Context(), w)
 
 	case http.MethodPost:
 		m, write, done := s.model.ForWriting()
@@ -573,15 +739,22 @@ func (s server) handleAdminEdit(w http.ResponseWriter, r *http.Request) error {
 			return fmt.Errorf("admin post: parse bieter edit: %s", errMsg)
 		}
 
-		if err := write(m.BieterUpdate(bieter)); err != nil {
-			return fmt.Errorf("admin post: write bieter update event: %w", err)
+		if err := write(m.BieterUpdate(bieter.ID, bieter.Version, bieter.Stammdaten)); err != nil {
+			var errValidation sticky.ValidationError
+			if !errors.As(err, &errValidation) {
+				return fmt.Errorf("admin post: write bieter update event: %w", err)
+			}
+			fieldErrors := bieter.InvalidFields()
+			fieldErrors["form"] = userError(err)
+			w.Header().Add("HX-Reswap", "none")
+			return template.AdminBieterEdit(bieter, m.VerteilstellenListe(), s.cfg.Glaeubiger.Mandatsreferenz(bieter.ID, bieter.Mandat.Nummer), fieldErrors).Render(r.Context(), w)
 		}
 
 		if err := template.AdminModalEmpty().Render(r.Context(), w); err != nil {
 			return err
 		}
 
-		return template.AdminUserTable(adminBieterList(m), getSort(r)).Render(r.Context(), w)
+		return template.AdminUserTable(adminBieterList(m), m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w)
 
 	default:
 		http.Error(w, "Fehler", http.StatusMethodNotAllowed)
@@ -604,7 +777,7 @@ func (s server) handleAdminDelete(w http.ResponseWriter, r *http.Request) error
 	}
 
 	bieter := adminBieterList(m)
-	return template.AdminUserTable(bieter, getSort(r)).Render(r.Context(), w)
+	return template.AdminUserTable(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w)
 }
 
 func (s server) handleAdminAnwesend(w http.ResponseWriter, r *http.Request) error {
@@ -622,7 +795,7 @@ func (s server) handleAdminAnwesend(w http.ResponseWriter, r *http.Request) erro
 	}
 
 	bieter := adminBieterList(m)
-	return template.AdminUserTable(bieter, getSort(r)).Render(r.Context(), w)
+	return template.AdminUserTable(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w)
 }
 
 func (s server) handleAdminSetCanSelfEdit(w http.ResponseWriter, r *http.Request) error {
@@ -640,7 +813,7 @@ func (s server) handleAdminSetCanSelfEdit(w http.ResponseWriter, r *http.Request
 	}
 
 	bieter := adminBieterList(m)
-	return template.AdminUserTable(bieter, getSort(r)).Render(r.Context(), w)
+	return template.AdminUserTable(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w)
 }
 
 func (s server) handleAdminSetCanNotSelfEdit(w http.ResponseWriter, r *http.Request) error {
@@ -658,7 +831,7 @@ func (s server) handleAdminSetCanNotSelfEdit(w http.ResponseWriter, r *http.Requ
 	}
 
 	bieter := adminBieterList(m)
-	return template.AdminUserTable(bieter, getSort(r)).Render(r.Context(), w)
+	return template.AdminUserTable(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w)
 }
 
 func (s server) handleAdminAbwesend(w http.ResponseWriter, r *http.Request) error {
@@ -676,24 +849,29 @@ func (s server) handleAdminAbwesend(w http.ResponseWriter, r *http.Request) erro
 	}
 
 	bieter := adminBieterList(m)
-	return template.AdminUserTable(bieter, getSort(r)).Render(r.Context(), w)
+	return template.AdminUserTable(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w)
 }
 
-func (s server) handleAdminResetGebot(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Hier wird nur gelöscht", http.StatusMethodNotAllowed)
+func (s server) handleAdminNeueRunde(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
 		return nil
 	}
 
 	m, write, done := s.model.ForWriting()
 	defer done()
 
-	if err := write(m.ResetGebot()); err != nil {
-		return err
+	if err := write(m.NeueRunde()); err != nil {
+		var errValidation sticky.ValidationError
+		if !errors.As(err, &errValidation) {
+			return err
+		}
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError(userError(err)).Render(r.Context(), w)
 	}
 
 	bieter := adminBieterList(m)
-	return template.AdminUserTable(bieter, getSort(r)).Render(r.Context(), w)
+	return template.AdminUserTable(bieter, m.VerteilstellenListe(), m.AlleRunden(), m.OffeneRuecklastschriften(), getSort(r)).Render(r.Context(), w)
 }
 
 func (s server) handleAdminState(w http.ResponseWriter, r *http.Request) error {
@@ -716,6 +894,229 @@ func (s server) handleAdminState(w http.ResponseWriter, r *http.Request) error {
 	return template.AdminButtons(state).Render(r.Context(), w)
 }
 
+func (s server) handleAdminZielbetrag(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	betrag, err := model.GebotFromString(r.Form.Get("zielbetrag"))
+	if err != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError("Der Zielbetrag muss eine Zahl sein.").Render(r.Context(), w)
+	}
+
+	if err := s.model.Write(func(m model.Model) model.Event { return m.SetZielbetrag(betrag) }); err != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError(userError(err)).Render(r.Context(), w)
+	}
+
+	m, done := s.model.ForReading()
+	defer done()
+	return template.AdminDeckung(adminDeckung(m)).Render(r.Context(), w)
+}
+
+func (s server) handleAdminSaison(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	saison := parseSaison(r)
+	if err := s.model.Write(func(m model.Model) model.Event { return m.SetSaison(saison) }); err != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError(userError(err)).Render(r.Context(), w)
+	}
+
+	m, done := s.model.ForReading()
+	defer done()
+	return template.AdminSaison(m.Saison).Render(r.Context(), w)
+}
+
+func (s server) handleAdminGebotsregeln(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	parseGrenzen := func(prefix string) (model.Gebotsgrenzen, error) {
+		var grenzen model.Gebotsgrenzen
+		var err error
+
+		if grenzen.Minimum, err = gebotOrEmpty(r.Form.Get(prefix + "_minimum")); err != nil {
+			return grenzen, err
+		}
+
+		if grenzen.Maximum, err = gebotOrEmpty(r.Form.Get(prefix + "_maximum")); err != nil {
+			return grenzen, err
+		}
+
+		if v := strings.TrimSpace(r.Form.Get(prefix + "_max_prozent")); v != "" {
+			if grenzen.MaxProzent, err = strconv.Atoi(v); err != nil {
+				return grenzen, err
+			}
+		}
+		return grenzen, nil
+	}
+
+	var regeln model.Gebotsregeln
+	var errGanz, errHalb error
+	regeln.Ganz, errGanz = parseGrenzen("ganz")
+	regeln.Halb, errHalb = parseGrenzen("halb")
+	if errGanz != nil || errHalb != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError("Die Grenzen müssen Zahlen sein.").Render(r.Context(), w)
+	}
+
+	if err := s.model.Write(func(m model.Model) model.Event { return m.SetGebotsregeln(regeln) }); err != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError(userError(err)).Render(r.Context(), w)
+	}
+
+	m, done := s.model.ForReading()
+	defer done()
+	return template.AdminGebotsregeln(m.Gebotsregeln).Render(r.Context(), w)
+}
+
+// gebotOrEmpty parses a gebot. An empty string is no gebot.
+func gebotOrEmpty(value string) (model.Gebot, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	return model.GebotFromString(value)
+}
+
+// parseSaison reads a saison from a parsed form. Invalid dates are zero and
+// are rejected by the validation of the event.
+func parseSaison(r *http.Request) model.Saison {
+	var saison model.Saison
+	saison.Name = strings.TrimSpace(r.Form.Get("name"))
+	saison.Start, _ = time.Parse("2006-01", r.Form.Get("start"))
+	saison.Ende, _ = time.Parse("2006-01", r.Form.Get("ende"))
+	saison.ErsteAbbuchung, _ = time.Parse(time.DateOnly, r.Form.Get("erste_abbuchung"))
+	saison.MandatDatum, _ = time.Parse(time.DateOnly, r.Form.Get("mandat_datum"))
+	return saison
+}
+
+func (s server) handleSaisonNeu(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		m, done := s.model.ForReading()
+		defer done()
+
+		return template.SaisonNeu(m.Saison.Folgejahr(), adminBieterList(m), nil, "").Render(r.Context(), w)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	saison := parseSaison(r)
+
+	var bietIDs []int
+	for _, value := range r.Form["bieter"] {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		bietIDs = append(bietIDs, id)
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err := write(m.SaisonNeu(saison, bietIDs)); err != nil {
+		return template.SaisonNeu(saison, adminBieterList(m), bietIDs, userError(err)).Render(r.Context(), w)
+	}
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleArchiv(w http.ResponseWriter, r *http.Request) error {
+	m, done := s.model.ForReading()
+	defer done()
+
+	return template.ArchivListe(m.ArchivListe()).Render(r.Context(), w)
+}
+
+func (s server) handleArchivDetail(w http.ResponseWriter, r *http.Request) error {
+	nummer, _ := strconv.Atoi(mux.Vars(r)["nr"])
+
+	m, done := s.model.ForReading()
+	defer done()
+
+	archiv, ok := m.Archiv[nummer]
+	if !ok {
+		http.Error(w, "Gemüsejahr nicht gefunden", http.StatusNotFound)
+		return nil
+	}
+
+	return template.ArchivDetail(archiv, m.VerteilstellenListe()).Render(r.Context(), w)
+}
+
+func (s server) handleAdminRichtwert(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	ganz, err := gebotOrEmpty(r.Form.Get("ganz"))
+	if err != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError("Der Richtwert muss eine Zahl sein.").Render(r.Context(), w)
+	}
+
+	halb, err := gebotOrEmpty(r.Form.Get("halb"))
+	if err != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError("Der Richtwert muss eine Zahl sein.").Render(r.Context(), w)
+	}
+
+	richtwert := model.Richtwert{Ganz: ganz, Halb: halb}
+	if err := s.model.Write(func(m model.Model) model.Event { return m.SetRichtwert(richtwert) }); err != nil {
+		w.Header().Add("HX-Reswap", "none")
+		return template.AdminError(userError(err)).Render(r.Context(), w)
+	}
+
+	m, done := s.model.ForReading()
+	defer done()
+	return template.AdminDeckung(adminDeckung(m)).Render(r.Context(), w)
+}
+
+func adminDeckung(m model.Model) template.AdminDeckungData {
+	unter, gleich, ueber := m.RichtwertVergleich()
+	return template.AdminDeckungData{
+		Zielbetrag:  m.Zielbetrag,
+		Jahressumme: m.Jahressumme(),
+		Prozent:     m.DeckungProzent(),
+		Fehlbetrag:  m.Fehlbetrag(),
+
+		Richtwert:       m.Richtwert,
+		UnterRichtwert:  unter,
+		GleichRichtwert: gleich,
+		UeberRichtwert:  ueber,
+
+		Anteile: m.Anteile(),
+		Paare:   m.Paare(),
+	}
+}
+
 func (s server) handleAdminZIP(w http.ResponseWriter, _ *http.Request) error {
 	w.Header().Add("Content-Type", "application/zip")
 	w.Header().Add("Content-Disposition", `attachment; filename="bieter.zip"`)
@@ -726,14 +1127,15 @@ func (s server) handleAdminZIP(w http.ResponseWriter, _ *http.Request) error {
 	zipW := zip.NewWriter(w)
 	defer zipW.Close()
 
-	var bieterList = slices.Collect(maps.Values(m.Bieter))
+	// The csv files and the xml file contain the same direct debits.
+	lastschriften, _ := m.EinzugLastschriften(m.Saison.Start)
 
 	fileJaehrlich, err := zipW.Create("Lastschrifteinzug_jaehrlich.csv")
 	if err != nil {
 		return fmt.Errorf("create csv file for lastschrifteinzug jaehrlich: %w", err)
 	}
 
-	if err := writeSEPACSVToZip(fileJaehrlich, bieterList, true); err != nil {
+	if err := writeSEPACSVToZip(fileJaehrlich, lastschriften, m.Bieter, m.Saison, s.cfg.Glaeubiger, true); err != nil {
 		return fmt.Errorf("write csv file for lastschrifteinzug jaehrlich: %w", err)
 	}
 
@@ -742,49 +1144,744 @@ func (s server) handleAdminZIP(w http.ResponseWriter, _ *http.Request) error {
 		return fmt.Errorf("create csv file for lastschrifteinzug monatlich: %w", err)
 	}
 
-	if err := writeSEPACSVToZip(fileMonatlich, bieterList, false); err != nil {
+	if err := writeSEPACSVToZip(fileMonatlich, lastschriften, m.Bieter, m.Saison, s.cfg.Glaeubiger, false); err != nil {
 		return fmt.Errorf("write csv file for lastschrifteinzug monatlich: %w", err)
 	}
 
+	fileXML, err := zipW.Create("Lastschrifteinzug.xml")
+	if err != nil {
+		return fmt.Errorf("create xml file for lastschrifteinzug: %w", err)
+	}
+
+	ersterEinzug := model.Einzug{
+		Monat:         m.Saison.Start,
+		Faelligkeit:   m.Saison.ErsteAbbuchung,
+		Exportiert:    time.Now(),
+		Lastschriften: lastschriften,
+	}
+	if err := writePain008(fileXML, ersterEinzug, m.Bieter, m.Saison, s.cfg.Glaeubiger); err != nil {
+		return fmt.Errorf("write xml file for lastschrifteinzug: %w", err)
+	}
+
 	return nil
 }
 
-func (s server) handleVerteilstellen(w http.ResponseWriter, r *http.Request) error {
-
+func (s server) handleAdminDatev(w http.ResponseWriter, _ *http.Request) error {
 	m, done := s.model.ForReading()
 	defer done()
 
-	ordered := make(map[model.Verteilstelle][]string)
-	for _, bieter := range m.Bieter {
-		v := bieter.Verteilstelle
-		if _, ok := ordered[v]; !ok {
-			ordered[v] = make([]string, 0)
+	if err := s.cfg.Datev.Bereit(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return nil
+	}
+
+	buchungen := datevBuchungen(m, s.cfg.Datev)
+	if len(buchungen) == 0 {
+		http.Error(w, "Es gibt keine Gebote, die gebucht werden können", http.StatusNotFound)
+		return nil
+	}
+
+	w.Header().Add("Content-Type", "application/zip")
+	w.Header().Add("Content-Disposition", `attachment; filename="DATEV.zip"`)
+
+	zipW := zip.NewWriter(w)
+	defer zipW.Close()
+
+	erzeugt := time.Now()
+	for jahr, stapel := range datevStapel(buchungen, s.cfg.Datev.WirtschaftsjahrBeginn) {
+		file, err := zipW.Create(fmt.Sprintf("EXTF_Buchungsstapel_%d.csv", jahr))
+		if err != nil {
+			return fmt.Errorf("create buchungsstapel for %d: %w", jahr, err)
 		}
-		name := bieter.Name()
-		if name == "" {
-			continue
+
+		if err := writeDatev(file, stapel, s.cfg.Datev, erzeugt); err != nil {
+			return fmt.Errorf("write buchungsstapel for %d: %w", jahr, err)
 		}
-		ordered[v] = append(ordered[v], name)
 	}
 
-	return template.Verteilstellen(ordered).Render(r.Context(), w)
+	return nil
 }
 
-func (s server) handleAdminSSE(w http.ResponseWriter, r *http.Request) error {
-	w.Header().Add("Content-Type", "text/event-stream")
-	w.Header().Add("Content-Disposition", "inline")
+func (s server) handleAdminBieterExport(w http.ResponseWriter, r *http.Request) error {
+	m, done := s.model.ForReading()
+	defer done()
 
-	if ok := s.sendAdminButtons(r.Context(), w); !ok {
+	verteilstellen := m.VerteilstellenListe()
+	bieter := model.SortBieter(adminBieterList(m), verteilstellen, model.ParseSortAttr(getSort(r)))
+	tabelle := bieterTabelle(bieter, verteilstellen, s.cfg.Glaeubiger)
+
+	query := r.URL.Query()
+	switch query.Get("format") {
+	case "xlsx":
+		w.Header().Add("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Add("Content-Disposition", `attachment; filename="Bieter.xlsx"`)
+		if err := writeXLSX(w, "Bieter", tabelle); err != nil {
+			return fmt.Errorf("write xlsx: %w", err)
+		}
 		return nil
-	}
 
-	s.model.Listen(r.Context())(func(events []string) bool {
-		if slices.Contains(events, "set-state") {
-			return s.sendAdminButtons(r.Context(), w)
+	case "csv":
+		trennzeichen := cmp.Or(query.Get("trennzeichen"), "semikolon")
+		kodierung := cmp.Or(query.Get("kodierung"), "utf-8")
+		if _, ok := csvTrennzeichen[trennzeichen]; !ok {
+			http.Error(w, "Unbekanntes Trennzeichen", http.StatusBadRequest)
+			return nil
 		}
-		return true
-	})
-	return nil
+		if _, ok := csvKodierungen[kodierung]; !ok {
+			http.Error(w, "Unbekannte Zeichenkodierung", http.StatusBadRequest)
+			return nil
+		}
+
+		charset := "utf-8"
+		if kodierung == "windows-1252" {
+			charset = kodierung
+		}
+		w.Header().Add("Content-Type", "text/csv; charset="+charset)
+		w.Header().Add("Content-Disposition", `attachment; filename="Bieter.csv"`)
+		if err := writeCSV(w, tabelle, trennzeichen, kodierung); err != nil {
+			return fmt.Errorf("write csv: %w", err)
+		}
+		return nil
+
+	default:
+		http.Error(w, "Unbekanntes Format", http.StatusBadRequest)
+		return nil
+	}
+}
+
+// handleAdminImport imports bieter from a csv file. The admin uploads the
+// file, assigns the columns to the fields and selects the rows in a preview.
+// The text of the file is sent back with every step.
+func (s server) handleAdminImport(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return template.BieterImport(template.BieterImportDaten{}, "").Render(r.Context(), w)
+	}
+
+	var text, schritt string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		datei, err := readUpload(r)
+		if err != nil {
+			return template.BieterImport(template.BieterImportDaten{}, err.Error()).Render(r.Context(), w)
+		}
+		text = importDekodieren(datei)
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		text = r.Form.Get("daten")
+		schritt = r.Form.Get("schritt")
+	}
+
+	zeilen, err := readImportCSV(text)
+	if err != nil {
+		return template.BieterImport(template.BieterImportDaten{}, err.Error()).Render(r.Context(), w)
+	}
+
+	daten := template.BieterImportDaten{
+		Text:      text,
+		Kopf:      zeilen[0],
+		Beispiel:  zeilen[1],
+		Zuordnung: importZuordnungRaten(zeilen[0]),
+	}
+	if schritt != "" {
+		for i := range daten.Zuordnung {
+			daten.Zuordnung[i] = r.Form.Get(fmt.Sprintf("spalte-%d", i))
+		}
+	}
+
+	if schritt == "" || schritt == "zuordnung" {
+		for _, feld := range importFelder {
+			daten.Felder = append(daten.Felder, feld.ImportFeld)
+		}
+		return template.BieterImport(daten, "").Render(r.Context(), w)
+	}
+
+	vorschau := func(m model.Model) []model.Stammdaten {
+		daten.Verteilstellen = m.VerteilstellenListe()
+		stammdaten, hinweise := importZeilen(zeilen[1:], daten.Zuordnung, daten.Verteilstellen)
+		daten.Zeilen = importVorschau(m, stammdaten, hinweise)
+		return stammdaten
+	}
+
+	if schritt != "import" {
+		m, done := s.model.ForReading()
+		vorschau(m)
+		done()
+		return template.BieterImport(daten, "").Render(r.Context(), w)
+	}
+
+	// The preview is built again with the write lock, so the duplicates are
+	// checked against the current bieter.
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	stammdaten := vorschau(m)
+
+	var ausgewaehlt []model.Stammdaten
+	var doppelt []string
+	for _, nummer := range r.Form["zeile"] {
+		n, err := strconv.Atoi(nummer)
+		if err != nil || n < 1 || n > len(stammdaten) {
+			continue
+		}
+
+		// A row can become a duplicate after the preview, for example if
+		// the form is sent twice.
+		if zeile := daten.Zeilen[n-1]; zeile.Duplikat != "" {
+			doppelt = append(doppelt, fmt.Sprintf("Zeile %d (%s)", n, zeile.Duplikat))
+			continue
+		}
+		ausgewaehlt = append(ausgewaehlt, stammdaten[n-1])
+	}
+
+	if len(doppelt) > 0 {
+		return template.BieterImport(daten, "Doppelte Bieter werden nicht importiert: "+strings.Join(doppelt, ", ")).Render(r.Context(), w)
+	}
+
+	if len(ausgewaehlt) == 0 {
+		return template.BieterImport(daten, "Es wurde keine Zeile ausgewählt").Render(r.Context(), w)
+	}
+
+	if err := write(m.BieterImport(ausgewaehlt)...); err != nil {
+		return template.BieterImport(daten, userError(err)).Render(r.Context(), w)
+	}
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleEinzug(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		m, done := s.model.ForReading()
+		defer done()
+
+		var exportiert model.Einzug
+		if monat, err := time.Parse("2006-01", r.URL.Query().Get("exportiert")); err == nil {
+			exportiert, _ = m.Einzug(monat)
+		}
+
+		versendet := -1
+		if n, err := strconv.Atoi(r.URL.Query().Get("versendet")); err == nil {
+			versendet = n
+		}
+
+		return template.Einzuege(m.State, m.Saison, m.EinzugUebersicht(), m.Aufteilungen(), s.mail != nil, versendet, exportiert, nil, "").Render(r.Context(), w)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	versendet := -1
+	monat, err := time.Parse("2006-01", r.Form.Get("monat"))
+	if err != nil {
+		return template.Einzuege(m.State, m.Saison, m.EinzugUebersicht(), m.Aufteilungen(), s.mail != nil, versendet, model.Einzug{}, nil, "Ungültiger Monat").Render(r.Context(), w)
+	}
+
+	erneut := r.Form.Has("erneut")
+	if einzug, ok := m.Einzug(monat); ok && !erneut {
+		return template.Einzuege(m.State, m.Saison, m.EinzugUebersicht(), m.Aufteilungen(), s.mail != nil, versendet, model.Einzug{}, &einzug, "").Render(r.Context(), w)
+	}
+
+	// The bank needs the file at least one working day before the due date.
+	faelligkeit := m.Faelligkeit(monat)
+	if frueheste := model.NaechsterBankarbeitstag(time.Now().AddDate(0, 0, 1)); faelligkeit.Before(frueheste) {
+		faelligkeit = frueheste
+	}
+
+	if err := write(m.EinzugExport(monat, faelligkeit, erneut)); err != nil {
+		return template.Einzuege(m.State, m.Saison, m.EinzugUebersicht(), m.Aufteilungen(), s.mail != nil, versendet, model.Einzug{}, nil, userError(err)).Render(r.Context(), w)
+	}
+
+	http.Redirect(w, r, "/admin/einzug?exportiert="+monat.Format("2006-01"), http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleEinzugDownload(w http.ResponseWriter, r *http.Request) error {
+	monat, err := time.Parse("2006-01", mux.Vars(r)["monat"])
+	if err != nil {
+		http.Error(w, "Ungültiger Monat", http.StatusBadRequest)
+		return nil
+	}
+
+	m, done := s.model.ForReading()
+	defer done()
+
+	einzug, ok := m.Einzug(monat)
+	if !ok {
+		http.Error(w, "Der Einzug wurde noch nicht exportiert", http.StatusNotFound)
+		return nil
+	}
+
+	w.Header().Add("Content-Type", "application/xml")
+	w.Header().Add("Content-Disposition", fmt.Sprintf(`attachment; filename="Lastschrifteinzug_%s.xml"`, monat.Format("2006-01")))
+	if err := writePain008(w, einzug, m.Bieter, m.Saison, s.cfg.Glaeubiger); err != nil {
+		return fmt.Errorf("write pain.008 for %s: %w", monat.Format("2006-01"), err)
+	}
+	return nil
+}
+
+func (s server) handleAdminVorabankuendigung(w http.ResponseWriter, _ *http.Request) error {
+	m, done := s.model.ForReading()
+	defer done()
+
+	if m.State != model.StateFinish {
+		http.Error(w, "Die Vorabankündigungen gibt es erst, wenn die Bietrunde abgeschlossen ist", http.StatusNotFound)
+		return nil
+	}
+
+	w.Header().Add("Content-Type", "application/zip")
+	w.Header().Add("Content-Disposition", `attachment; filename="Vorabankuendigungen.zip"`)
+
+	zipW := zip.NewWriter(w)
+	defer zipW.Close()
+
+	for bietID, termine := range m.Zahlungsplaene() {
+		bieter := m.Bieter[bietID]
+		dokument, err := pdf.Vorabankuendigung(bieter, termine, m.Saison, s.cfg.Glaeubiger)
+		if err != nil {
+			return fmt.Errorf("create vorabankuendigung for bieter %d: %w", bietID, err)
+		}
+
+		file, err := zipW.Create(fmt.Sprintf("Vorabankuendigung_%d.pdf", bietID))
+		if err != nil {
+			return fmt.Errorf("create pdf file for bieter %d: %w", bietID, err)
+		}
+
+		if _, err := file.Write(dokument); err != nil {
+			return fmt.Errorf("write pdf file for bieter %d: %w", bietID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s server) handleAdminVorabankuendigungMail(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	m, done := s.model.ForReading()
+	defer done()
+
+	if m.State != model.StateFinish {
+		http.Error(w, "Die Vorabankündigungen gibt es erst, wenn die Bietrunde abgeschlossen ist", http.StatusNotFound)
+		return nil
+	}
+
+	var empfaenger []model.Bieter
+	plaene := m.Zahlungsplaene()
+	for bietID := range plaene {
+		if bieter := m.Bieter[bietID]; bieter.Mail != "" {
+			empfaenger = append(empfaenger, bieter)
+		}
+	}
+
+	go s.sendVorabankuendigungen(empfaenger, plaene, m.Saison)
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/einzug?versendet=%d", len(empfaenger)), http.StatusSeeOther)
+	return nil
+}
+
+// sendVorabankuendigungen sends the pre-notification of the direct debits to
+// the bieter.
+func (s server) sendVorabankuendigungen(empfaenger []model.Bieter, plaene map[int][]model.Termin, saison model.Saison) {
+	for _, bieter := range empfaenger {
+		var body strings.Builder
+		fmt.Fprintf(&body, "Hallo %s,\n\n", bieter.Name())
+		fmt.Fprintf(&body, "für deinen Gemüseanteil im Gemüsejahr %s buchen wir die folgenden Beträge per SEPA-Lastschrift von deinem Konto ab:\n\n", saison.Name)
+		for _, termin := range plaene[bieter.ID] {
+			fmt.Fprintf(&body, "%s  %s\n", termin.Faelligkeit.Format("02.01.2006"), termin.Betrag)
+		}
+		fmt.Fprintf(&body, "\nMandatsreferenz: %s\n", s.cfg.Glaeubiger.Mandatsreferenz(bieter.ID, bieter.Mandat.Nummer))
+		fmt.Fprintf(&body, "Gläubiger-Identifikationsnummer: %s\n", s.cfg.Glaeubiger.GlaeubigerID)
+		fmt.Fprintf(&body, "\nDie Vorabankündigung als PDF findest du auf deiner Seite unter %s/?biet-id=%d\n", s.cfg.BaseURL, bieter.ID)
+
+		if err := s.mail.Send(bieter.Mail, "Vorabankündigung der SEPA-Lastschriften", body.String()); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
+}
+
+func (s server) handleRuecklastschriften(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		m, done := s.model.ForReading()
+		defer done()
+
+		return template.Ruecklastschriften(m.RuecklastschriftenListe(), m.Bieter, nil, "").Render(r.Context(), w)
+	}
+
+	buchungen, err := readCamtUpload(r)
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err != nil {
+		return template.Ruecklastschriften(m.RuecklastschriftenListe(), m.Bieter, nil, err.Error()).Render(r.Context(), w)
+	}
+
+	zuordnung := camtZuordnen(m, buchungen, s.cfg.Glaeubiger)
+	if err := write(m.RuecklastschriftenErfassen(zuordnung.Ruecklastschriften)...); err != nil {
+		return template.Ruecklastschriften(m.RuecklastschriftenListe(), m.Bieter, nil, userError(err)).Render(r.Context(), w)
+	}
+
+	ergebnis := template.RuecklastschriftImport{
+		Erfasst: len(zuordnung.Ruecklastschriften),
+		Bekannt: zuordnung.Bekannt,
+	}
+	for _, u := range zuordnung.Unbekannt {
+		ergebnis.Unbekannt = append(ergebnis.Unbekannt, fmt.Sprintf("%s: %s, Mandat %q, Referenz %q, Grund %s", u.Datum.Format("02.01.2006"), u.Betrag, u.Mandatsreferenz, u.EndToEndID, u.Grund))
+	}
+
+	return template.Ruecklastschriften(m.RuecklastschriftenListe(), m.Bieter, &ergebnis, "").Render(r.Context(), w)
+}
+
+func (s server) handleAbgleich(w http.ResponseWriter, r *http.Request) error {
+	stichtag := time.Now()
+	if t, err := time.Parse(time.DateOnly, r.URL.Query().Get("stichtag")); err == nil {
+		stichtag = t
+	}
+
+	if r.Method != http.MethodPost {
+		m, done := s.model.ForReading()
+		defer done()
+
+		return template.Abgleich(m.Abgleich(stichtag), stichtag, nil, "").Render(r.Context(), w)
+	}
+
+	buchungen, err := readCamtUpload(r)
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err != nil {
+		return template.Abgleich(m.Abgleich(stichtag), stichtag, nil, err.Error()).Render(r.Context(), w)
+	}
+
+	abgleich := camtZahlungenZuordnen(m, buchungen, s.cfg.Glaeubiger)
+	if err := write(m.ZahlungenErfassen(abgleich.Zahlungen)...); err != nil {
+		return template.Abgleich(m.Abgleich(stichtag), stichtag, nil, userError(err)).Render(r.Context(), w)
+	}
+
+	ergebnis := template.ZahlungImport{
+		Erfasst: len(abgleich.Zahlungen),
+		Bekannt: abgleich.Bekannt,
+	}
+	for _, u := range abgleich.Unbekannt {
+		ergebnis.Unbekannt = append(ergebnis.Unbekannt, fmt.Sprintf("%s: %s von %s (%s), Verwendungszweck %q", u.Datum.Format("02.01.2006"), u.Betrag, cmp.Or(u.Name, "unbekannt"), cmp.Or(u.IBAN, "ohne IBAN"), u.Verwendungszweck))
+	}
+
+	return template.Abgleich(m.Abgleich(stichtag), stichtag, &ergebnis, "").Render(r.Context(), w)
+}
+
+// readCamtUpload reads the transactions from the uploaded camt file.
+func readCamtUpload(r *http.Request) ([]camtBuchung, error) {
+	datei, err := readUpload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return readCamt(bytes.NewReader(datei))
+}
+
+// readUpload returns the content of the uploaded file with the name "datei".
+func readUpload(r *http.Request) ([]byte, error) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		return nil, fmt.Errorf("Die Datei konnte nicht hochgeladen werden")
+	}
+
+	file, _, err := r.FormFile("datei")
+	if err != nil {
+		return nil, fmt.Errorf("Es wurde keine Datei ausgewählt")
+	}
+	defer file.Close()
+
+	datei, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("Die Datei konnte nicht gelesen werden")
+	}
+	return datei, nil
+}
+
+func (s server) handleRuecklastschriftNachholen(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err := write(m.RuecklastschriftNachholen(id, r.Form.Has("nachholen"))); err != nil {
+		return template.Ruecklastschriften(m.RuecklastschriftenListe(), m.Bieter, nil, userError(err)).Render(r.Context(), w)
+	}
+
+	http.Redirect(w, r, "/admin/ruecklastschriften", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleVerteilstellen(w http.ResponseWriter, r *http.Request) error {
+	m, done := s.model.ForReading()
+	defer done()
+
+	return s.showVerteilstellen(w, r, m, "")
+}
+
+func (s server) showVerteilstellen(w http.ResponseWriter, r *http.Request, m model.Model, errMsg string) error {
+	namen := make(map[int][]string)
+	for _, bieter := range adminBieterList(m) {
+		name := bieter.Name()
+		if name == "" || bieter.Warteliste {
+			continue
+		}
+
+		if partner, ok := m.Teilpartner(bieter); ok {
+			// A pair of teilpartner gets one anteil, so they are listed
+			// together.
+			if partner.ID < bieter.ID && partner.VerteilstelleID == bieter.VerteilstelleID {
+				continue
+			}
+			name = fmt.Sprintf("%s und %s", name, partner.Name())
+		}
+		namen[bieter.VerteilstelleID] = append(namen[bieter.VerteilstelleID], name)
+	}
+
+	return template.Verteilstellen(m.VerteilstellenListe(), namen, belegung(m), errMsg).Render(r.Context(), w)
+}
+
+func belegung(m model.Model) map[int]float64 {
+	belegung := make(map[int]float64, len(m.Verteilstellen))
+	for id := range m.Verteilstellen {
+		belegung[id] = m.Belegung(id)
+	}
+	return belegung
+}
+
+func parseVerteilstelle(r *http.Request, verteilstelle model.Verteilstelle) (model.Verteilstelle, error) {
+	if err := r.ParseForm(); err != nil {
+		return verteilstelle, fmt.Errorf("parsing form: %w", err)
+	}
+
+	abholtag, _ := strconv.Atoi(r.Form.Get("abholtag"))
+	kapazitaet, _ := strconv.Atoi(r.Form.Get("kapazitaet"))
+
+	verteilstelle.Name = strings.TrimSpace(r.Form.Get("name"))
+	verteilstelle.Adresse = strings.TrimSpace(r.Form.Get("adresse"))
+	verteilstelle.Abholtag = model.Wochentag(abholtag)
+	verteilstelle.Abholzeit = strings.TrimSpace(r.Form.Get("abholzeit"))
+	verteilstelle.Kontakt = strings.TrimSpace(r.Form.Get("kontakt"))
+	verteilstelle.Kapazitaet = max(kapazitaet, 0)
+	return verteilstelle, nil
+}
+
+func (s server) handleVerteilstelleNew(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	verteilstelle, err := parseVerteilstelle(r, model.Verteilstelle{})
+	if err != nil {
+		return err
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	_, event := m.VerteilstelleCreate(verteilstelle)
+	if err := write(event); err != nil {
+		return s.showVerteilstellen(w, r, m, userError(err))
+	}
+
+	http.Redirect(w, r, "/admin/verteilstellen", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleVerteilstelleEdit(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	verteilstelle, err := parseVerteilstelle(r, model.Verteilstelle{ID: id})
+	if err != nil {
+		return err
+	}
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err := write(m.VerteilstelleUpdate(verteilstelle)); err != nil {
+		return s.showVerteilstellen(w, r, m, userError(err))
+	}
+
+	http.Redirect(w, r, "/admin/verteilstellen", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleVerteilstelleAktiv(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	aktiv := r.Form.Get("aktiv") == "true"
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err := write(m.VerteilstelleSetAktiv(id, aktiv)); err != nil {
+		return s.showVerteilstellen(w, r, m, userError(err))
+	}
+
+	http.Redirect(w, r, "/admin/verteilstellen", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleWarteliste(w http.ResponseWriter, r *http.Request) error {
+	m, done := s.model.ForReading()
+	defer done()
+
+	return s.showWarteliste(w, r, m, "")
+}
+
+func (s server) showWarteliste(w http.ResponseWriter, r *http.Request, m model.Model, errMsg string) error {
+	warteliste := make(map[int][]model.Bieter, len(m.Verteilstellen))
+	for id := range m.Verteilstellen {
+		warteliste[id] = m.Warteliste(id)
+	}
+
+	return template.Warteliste(m.VerteilstellenListe(), warteliste, belegung(m), errMsg).Render(r.Context(), w)
+}
+
+func (s server) handleWartelisteZuweisen(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Hier wird nur geupdated", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	bietID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	verteilstelleID, _ := strconv.Atoi(r.Form.Get("verteilstelle"))
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err := write(m.VerteilstelleZuweisen(bietID, verteilstelleID)); err != nil {
+		return s.showWarteliste(w, r, m, userError(err))
+	}
+
+	http.Redirect(w, r, "/admin/warteliste", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) handleTeilpartnersuche(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		m, done := s.model.ForReading()
+		defer done()
+
+		return s.showTeilpartnersuche(w, r, m, "")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	bietID, _ := strconv.Atoi(r.Form.Get("bieter"))
+	partnerID, _ := strconv.Atoi(r.Form.Get("partner"))
+
+	m, write, done := s.model.ForWriting()
+	defer done()
+
+	if err := write(m.TeilpartnerZuordnen(bietID, partnerID)); err != nil {
+		return s.showTeilpartnersuche(w, r, m, userError(err))
+	}
+
+	bieter := m.Bieter[bietID]
+	partner := m.Bieter[partnerID]
+	go s.sendTeilpartnerMail(bieter, partner)
+	go s.sendTeilpartnerMail(partner, bieter)
+
+	http.Redirect(w, r, "/admin/teilpartner", http.StatusSeeOther)
+	return nil
+}
+
+func (s server) showTeilpartnersuche(w http.ResponseWriter, r *http.Request, m model.Model, errMsg string) error {
+	vorschlaege := m.TeilpartnerVorschlaege()
+	return template.Teilpartnersuche(vorschlaege, m.OhneVorschlag(vorschlaege), m.VerteilstellenListe(), errMsg).Render(r.Context(), w)
+}
+
+// sendTeilpartnerMail tells a bieter, that a teilpartner was found. The mail
+// is only sent, if the bieter agreed to share the contact details. The
+// contact details of the partner are only included, if the partner agreed
+// too.
+func (s server) sendTeilpartnerMail(bieter, partner model.Bieter) {
+	if !bieter.KontaktTeilen {
+		return
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hallo %s,\n\n", bieter.Name())
+	fmt.Fprintf(&body, "für deinen halben Anteil wurde %s als Teilpartner gefunden.\n", partner.Name())
+	if partner.KontaktTeilen {
+		fmt.Fprintf(&body, "\nE-Mail: %s\nTelefon: %s\n", partner.Mail, partner.Telefon)
+	}
+	fmt.Fprintf(&body, "\nDeine Daten findest du unter %s/?biet-id=%d\n", s.cfg.BaseURL, bieter.ID)
+
+	if err := s.mail.Send(bieter.Mail, "Teilpartner gefunden", body.String()); err != nil {
+		log.Printf("Error: %v", err)
+	}
+}
+
+func (s server) handleAdminSSE(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Add("Content-Type", "text/event-stream")
+	w.Header().Add("Content-Disposition", "inline")
+
+	if ok := s.sendAdminButtons(r.Context(), w); !ok {
+		return nil
+	}
+
+	s.model.Listen(r.Context())(func(events []string) bool {
+		if slices.Contains(events, "set-state") {
+			if !s.sendAdminButtons(r.Context(), w) {
+				return false
+			}
+		}
+		return s.sendAdminDeckung(r.Context(), w)
+	})
+	return nil
+}
+
+func (s server) sendAdminDeckung(ctx context.Context, w http.ResponseWriter) bool {
+	m, done := s.model.ForReading()
+	deckung := adminDeckung(m)
+	done()
+
+	w.Write([]byte("event: deckung\ndata: "))
+	if err := template.AdminDeckung(deckung).Render(ctx, w); err != nil {
+		return false
+	}
+	w.Write([]byte("\n\n"))
+	w.(http.Flusher).Flush()
+	return true
 }
 
 func (s server) sendAdminButtons(ctx context.Context, w http.ResponseWriter) bool {
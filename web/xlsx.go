@@ -0,0 +1,158 @@
+package web
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxDateien are the files of a workbook with one sheet, except the sheet
+// itself.
+var xlsxDateien = []struct {
+	name   string
+	inhalt string
+}{
+	{
+		"[Content_Types].xml",
+		`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`</Types>`,
+	},
+	{
+		"_rels/.rels",
+		`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		"xl/_rels/workbook.xml.rels",
+		`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		// The styles are the default style, the header (bold) and amounts
+		// with two decimals.
+		"xl/styles.xml",
+		`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3">` +
+			`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+			`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`</cellXfs>` +
+			`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+			`</styleSheet>`,
+	},
+}
+
+// Styles of the cells in xl/styles.xml.
+const (
+	xlsxStilKopf   = 1
+	xlsxStilBetrag = 2
+)
+
+// writeXLSX writes a workbook with one sheet.
+//
+// The first row is the header. The values can be strings, ints or amounts
+// as float64. Strings are written inline, so no shared string table is
+// needed.
+func writeXLSX(w io.Writer, blatt string, zeilen [][]any) error {
+	zipW := zip.NewWriter(w)
+
+	for _, datei := range xlsxDateien {
+		if err := xlsxDatei(zipW, datei.name, datei.inhalt); err != nil {
+			return err
+		}
+	}
+
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + xlsxEscape(blatt) + `" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+	if err := xlsxDatei(zipW, "xl/workbook.xml", workbook); err != nil {
+		return err
+	}
+
+	var sheet strings.Builder
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, zeile := range zeilen {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, wert := range zeile {
+			ref := xlsxSpalte(j) + strconv.Itoa(i+1)
+			stil := ""
+			if i == 0 {
+				stil = fmt.Sprintf(` s="%d"`, xlsxStilKopf)
+			}
+
+			switch v := wert.(type) {
+			case int:
+				fmt.Fprintf(&sheet, `<c r="%s"%s><v>%d</v></c>`, ref, stil, v)
+			case float64:
+				fmt.Fprintf(&sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStilBetrag, strconv.FormatFloat(v, 'f', -1, 64))
+			case string:
+				if v == "" {
+					continue
+				}
+				fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, stil, xlsxEscape(v))
+			default:
+				return fmt.Errorf("unsupported value %T in row %d", wert, i+1)
+			}
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	if err := xlsxDatei(zipW, "xl/worksheets/sheet1.xml", sheet.String()); err != nil {
+		return err
+	}
+
+	if err := zipW.Close(); err != nil {
+		return fmt.Errorf("closing xlsx: %w", err)
+	}
+	return nil
+}
+
+func xlsxDatei(zipW *zip.Writer, name, inhalt string) error {
+	f, err := zipW.Create(name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+
+	if _, err := io.WriteString(f, inhalt); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// xlsxSpalte returns the name of a column, for example "A" for 0 and "AA"
+// for 26.
+func xlsxSpalte(index int) string {
+	var name []byte
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = append([]byte{byte('A' + (index-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func xlsxEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}